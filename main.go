@@ -16,8 +16,13 @@ var (
 	conn    *rtorrent.RTorrent
 
 	endpoint         string
+	username         string
+	password         string
 	view             string
 	hash             string
+	torrentPath      string
+	start            bool
+	withData         bool
 	disableCertCheck bool
 )
 
@@ -34,7 +39,7 @@ func initApp() *cli.App {
 	nApp.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:        "endpoint",
-			Usage:       "rTorrent endpoint",
+			Usage:       "rTorrent endpoint, a HTTP(S) URL, or scgi://host:port or the path of a unix socket for SCGI",
 			Value:       "http://myrtorrent/RPC2",
 			Destination: &endpoint,
 		},
@@ -43,10 +48,28 @@ func initApp() *cli.App {
 			Usage:       "disable certificate checking on this endpoint, useful for testing",
 			Destination: &disableCertCheck,
 		},
+		cli.StringFlag{
+			Name:        "username",
+			Usage:       "username for endpoints using HTTP basic authentication",
+			Destination: &username,
+		},
+		cli.StringFlag{
+			Name:        "password",
+			Usage:       "password for endpoints using HTTP basic authentication",
+			EnvVar:      "RTORRENT_PASSWORD",
+			Destination: &password,
+		},
 	}
 
 	nApp.Before = setupConnection
 
+	hashFlag := cli.StringFlag{
+		Name:        "hash",
+		Usage:       "hash of the torrent",
+		Value:       "unknown",
+		Destination: &hash,
+	}
+
 	nApp.Commands = []cli.Command{{
 		Name:   "get-ip",
 		Usage:  "retrieves the IP for this rTorrent instance",
@@ -59,6 +82,10 @@ func initApp() *cli.App {
 		Name:   "get-totals",
 		Usage:  "retrieves the up/down totals for this rTorrent instance",
 		Action: getTotals,
+	}, {
+		Name:   "status",
+		Usage:  "shows an overview of this rTorrent instance",
+		Action: getStatus,
 	}, {
 		Name:   "get-torrents",
 		Usage:  "retrieves the torrents from this rTorrent instance",
@@ -75,12 +102,43 @@ func initApp() *cli.App {
 		Name:   "get-files",
 		Usage:  "retrieves the files for a specific torrent",
 		Action: getFiles,
+		Flags:  []cli.Flag{hashFlag},
+	}, {
+		Name:   "start",
+		Usage:  "starts a specific torrent",
+		Action: startTorrent,
+		Flags:  []cli.Flag{hashFlag},
+	}, {
+		Name:   "stop",
+		Usage:  "stops a specific torrent",
+		Action: stopTorrent,
+		Flags:  []cli.Flag{hashFlag},
+	}, {
+		Name:   "delete",
+		Usage:  "removes a specific torrent",
+		Action: deleteTorrent,
+		Flags: []cli.Flag{
+			hashFlag,
+			cli.BoolFlag{
+				Name:        "with-data",
+				Usage:       "also delete the data of the torrent",
+				Destination: &withData,
+			},
+		},
+	}, {
+		Name:   "add-file",
+		Usage:  "adds a torrent from a local .torrent file",
+		Action: addFile,
 		Flags: []cli.Flag{
 			cli.StringFlag{
-				Name:        "hash",
-				Usage:       "hash of the torrent",
-				Value:       "unknown",
-				Destination: &hash,
+				Name:        "path",
+				Usage:       "path of the .torrent file",
+				Destination: &torrentPath,
+			},
+			cli.BoolFlag{
+				Name:        "start",
+				Usage:       "start the torrent once added",
+				Destination: &start,
 			},
 		},
 	},
@@ -91,7 +149,8 @@ func initApp() *cli.App {
 
 func main() {
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
@@ -100,6 +159,9 @@ func setupConnection(c *cli.Context) error {
 		return errors.New("endpoint must be specified")
 	}
 	conn = rtorrent.New(endpoint, disableCertCheck)
+	if username != "" {
+		conn.WithBasicAuth(username, password)
+	}
 	return nil
 }
 
@@ -138,6 +200,32 @@ func getTotals(c *cli.Context) error {
 	return nil
 }
 
+func getStatus(c *cli.Context) error {
+	stats, err := conn.GlobalStats()
+	if err != nil {
+		return errors.Wrap(err, "failed to get rTorrent stats")
+	}
+	fmt.Printf("Host:       %s (%s)\n", stats.Hostname, stats.IP)
+	fmt.Printf("Torrents:   %d\n", stats.Torrents)
+	fmt.Printf("Down:       %s (%s/s)\n", humanize(stats.DownTotal), humanize(int64(stats.DownRate)))
+	fmt.Printf("Up:         %s (%s/s)\n", humanize(stats.UpTotal), humanize(int64(stats.UpRate)))
+	return nil
+}
+
+// humanize formats a number of bytes using binary units, e.g. 1.5 MiB
+func humanize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
 func getTorrents(c *cli.Context) error {
 	torrents, err := conn.GetTorrents(rtorrent.View(view))
 	if err != nil {
@@ -159,3 +247,43 @@ func getFiles(c *cli.Context) error {
 	}
 	return nil
 }
+
+func startTorrent(c *cli.Context) error {
+	if err := conn.StartTorrent(rtorrent.Torrent{Hash: hash}); err != nil {
+		return errors.Wrap(err, "failed to start torrent")
+	}
+	fmt.Println("started", hash)
+	return nil
+}
+
+func stopTorrent(c *cli.Context) error {
+	if err := conn.StopTorrent(rtorrent.Torrent{Hash: hash}); err != nil {
+		return errors.Wrap(err, "failed to stop torrent")
+	}
+	fmt.Println("stopped", hash)
+	return nil
+}
+
+func deleteTorrent(c *cli.Context) error {
+	t := rtorrent.Torrent{Hash: hash}
+	if withData {
+		if err := conn.DeleteWithData(t); err != nil {
+			return errors.Wrap(err, "failed to delete torrent with data")
+		}
+	} else if err := conn.Delete(t); err != nil {
+		return errors.Wrap(err, "failed to delete torrent")
+	}
+	fmt.Println("deleted", hash)
+	return nil
+}
+
+func addFile(c *cli.Context) error {
+	if torrentPath == "" {
+		return errors.New("path must be specified")
+	}
+	if err := conn.AddFile(torrentPath, start); err != nil {
+		return errors.Wrap(err, "failed to add torrent file")
+	}
+	fmt.Println("added", torrentPath)
+	return nil
+}
@@ -1,18 +1,76 @@
+// Package rtorrent provides a client for the XMLRPC interface of rTorrent
+//
+// Not everything configured in rtorrent.rc can be read back over XMLRPC. Notably watch directories are set up with
+// schedule2 entries (e.g. "schedule2 = watch,5,5,load.start=/watch/*.torrent"), and rTorrent offers no command to
+// list scheduled tasks or their arguments, so they can't be discovered by this package. Expose the path in the
+// configuration instead, e.g. with "method.insert = cfg.watch, string|const, /watch" which can then be called like
+// any other command, or configure it on both sides.
 package rtorrent
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/mrobinsn/go-rtorrent/torrent"
 	"github.com/mrobinsn/go-rtorrent/xmlrpc"
 	"github.com/pkg/errors"
 )
 
+// ErrSessionNotConfigured is returned when rTorrent has no session directory to save to
+var ErrSessionNotConfigured = errors.New("rTorrent session directory is not configured")
+
+// ErrRequestTooLarge is returned when torrent data is larger than rTorrent accepts (network.xmlrpc.size_limit)
+var ErrRequestTooLarge = errors.New("XMLRPC request too large")
+
+// ErrNotPaused is returned by Resume when transfers were not paused with Pause, by any client
+var ErrNotPaused = errors.New("rTorrent transfers were not paused")
+
+// BatchError is returned by the operations over many torrents, like StartAll, when some of them failed
+// The operation was still applied to the other torrents.
+type BatchError struct {
+	// Errors holds the error of each torrent which failed, by hash
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	hashes := make([]string, 0, len(e.Errors))
+	for hash := range e.Errors {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	failures := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		failures = append(failures, fmt.Sprintf("%s: %v", hash, e.Errors[hash]))
+	}
+	return fmt.Sprintf("%d of the torrents failed: %s", len(hashes), strings.Join(failures, "; "))
+}
+
 // RTorrent is used to communicate with a remote rTorrent instance
 type RTorrent struct {
-	addr         string
+	addr   string
+	caller Caller
+	// xmlrpcClient is the caller when it is a xmlrpc.Client, which the With* methods configure, nil otherwise
 	xmlrpcClient *xmlrpc.Client
+	startOnAdd   bool
+	tagDelimiter string
+	// eraseDataHook delegates the deletion of the data to the erasedata plugin of ruTorrent, see WithEraseDataHook
+	eraseDataHook bool
+
+	// pauseMu serializes Pause and Resume, the limits they replace are stored on the server
+	pauseMu sync.Mutex
 }
 
 // FieldValue contains the Field and Value of an attribute on a rTorrent
@@ -22,33 +80,344 @@ type FieldValue struct {
 }
 
 // Torrent represents a torrent in rTorrent
+// Times are marshalled to JSON in RFC 3339 format. Created, Started and Finished are the Unix epoch when rTorrent
+// reports no time, AddedAt and FinishedAt are the zero time ("0001-01-01T00:00:00Z" in JSON), as are the times left
+// out by GetTorrentsWithFields.
 type Torrent struct {
-	Hash      string
-	Name      string
-	Path      string
-	Size      int
-	Label     string
-	Completed bool
-	Ratio     float64
-	Created   time.Time
-	Started   time.Time
-	Finished  time.Time
+	Hash       string    `json:"hash"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Label      string    `json:"label"`
+	Completed  bool      `json:"completed"`
+	Ratio      float64   `json:"ratio"`
+	Created    time.Time `json:"created"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	Uploaded   int64     `json:"uploaded"`
+	Downloaded int64     `json:"downloaded"`
+	Priority   Priority  `json:"priority"`
+	// State is derived from d.state, d.is_active, d.hashing and d.complete, see TorrentState
+	State TorrentState `json:"state"`
+	// Message is the last message reported for the torrent (d.message), usually why it is stuck, e.g. a tracker error
+	Message string `json:"message"`
+	// AddedAt is when the torrent was added to rTorrent (d.load_date), the zero time if unknown
+	AddedAt time.Time `json:"added_at"`
+	// FinishedAt is when the torrent finished downloading like Finished, but the zero time if it hasn't finished
+	FinishedAt time.Time `json:"finished_at"`
+	// CompletedBytes is how much of the torrent is downloaded (d.completed_bytes), see PercentComplete
+	CompletedBytes int64 `json:"completed_bytes"`
+}
+
+// PercentComplete returns how much of the torrent is downloaded, from 0 to 100
+// A torrent without a size, like a magnet link whose metadata isn't retrieved yet, is 0% complete unless Completed.
+func (t *Torrent) PercentComplete() float64 {
+	return percentComplete(t.CompletedBytes, t.Size, t.Completed)
+}
+
+// TorrentLite is a compact representation of a torrent, see GetTorrentsLite
+type TorrentLite struct {
+	Hash string `json:"hash"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// TorrentWithViews is a Torrent along with the names of the views it belongs to
+type TorrentWithViews struct {
+	Torrent
+	Views []string `json:"views"`
+}
+
+// HashingTorrent is a Torrent which is being hash checked, see GetHashingTorrents
+type HashingTorrent struct {
+	Torrent
+	// HashingProgress is the fraction of the chunks checked so far, from 0 to 1
+	HashingProgress float64 `json:"hashing_progress"`
 }
 
 // Status represents the status of a torrent
 type Status struct {
-	Completed      bool
-	CompletedBytes int
-	DownRate       int
-	UpRate         int
-	Ratio          float64
-	Size           int
+	Completed      bool    `json:"completed"`
+	CompletedBytes int64   `json:"completed_bytes"`
+	DownRate       int     `json:"down_rate"`
+	UpRate         int     `json:"up_rate"`
+	Ratio          float64 `json:"ratio"`
+	Size           int64   `json:"size"`
+	// CompletedChunks and SizeChunks allow computing the progress like rTorrent does, only counting verified chunks
+	CompletedChunks int64 `json:"completed_chunks"`
+	SizeChunks      int64 `json:"size_chunks"`
+	// ChunkSize is the size of a chunk in bytes, the last chunk of the torrent may be smaller
+	ChunkSize int64 `json:"chunk_size"`
+	// PeersConnected is the number of connected peers, of which SeedersConnected have the whole torrent and
+	// LeechersConnected don't (ruTorrent reports the latter as the connected peers)
+	PeersConnected    int `json:"peers_connected"`
+	SeedersConnected  int `json:"seeders_connected"`
+	LeechersConnected int `json:"leechers_connected"`
+}
+
+// PercentComplete returns how much of the torrent is downloaded, from 0 to 100, see Torrent.PercentComplete
+func (s *Status) PercentComplete() float64 {
+	return percentComplete(s.CompletedBytes, s.Size, s.Completed)
+}
+
+func percentComplete(completed, size int64, done bool) float64 {
+	if size <= 0 {
+		if done {
+			return 100
+		}
+		return 0
+	}
+	if completed >= size {
+		return 100
+	}
+	return float64(completed) * 100 / float64(size)
+}
+
+// ETA returns the time remaining until the torrent is complete at the current download rate, rounded up to the second
+// ok is false when it can't be estimated: the torrent is complete or isn't downloading (DownRate is zero).
+func (s *Status) ETA() (eta time.Duration, ok bool) {
+	remaining := s.Size - s.CompletedBytes
+	if s.Completed || remaining <= 0 || s.DownRate <= 0 {
+		return 0, false
+	}
+	seconds := (remaining + int64(s.DownRate) - 1) / int64(s.DownRate)
+	return time.Duration(seconds) * time.Second, true
+}
+
+// TorrentSnapshot bundles most of what is known about a torrent, see RTorrent.GetTorrentSnapshot
+type TorrentSnapshot struct {
+	// Torrent is populated by the same commands as GetTorrents (d.hash, d.name, d.directory, d.size_bytes, ...)
+	Torrent
+	// Status is populated by the same commands as GetStatus (d.complete, d.completed_bytes, d.down.rate, ...)
+	Status Status `json:"status"`
+	// State is determined from d.is_open, d.state, d.is_active, d.hashing, d.complete and d.message like DetailedState
+	// It shadows Torrent.State, which is still available as TorrentSnapshot.Torrent.State.
+	State DetailedState `json:"state"`
+	// TrackerCount is the number of trackers of the torrent (d.tracker_size)
+	TrackerCount int `json:"tracker_count"`
+}
+
+// Priority represents the priority of a torrent
+type Priority int
+
+const (
+	// PriorityOff is a torrent which does not transfer any data
+	PriorityOff Priority = iota
+	// PriorityLow is a torrent with a low priority
+	PriorityLow
+	// PriorityNormal is a torrent with a normal priority, the default
+	PriorityNormal
+	// PriorityHigh is a torrent with a high priority
+	PriorityHigh
+)
+
+// TorrentState represents whether a torrent is started, as reported in Torrent.State
+// The first matching rule determines the state:
+//   - StateHashing when d.hashing is not 0
+//   - StateStopped when d.state is 0
+//   - StateSeeding when d.is_active is 1 and d.complete is 1
+//   - StateStarted otherwise, including started torrents which are paused
+// See DetailedState for a finer grained state.
+type TorrentState int
+
+const (
+	// StateStopped is a torrent which is stopped
+	StateStopped TorrentState = iota
+	// StateStarted is a torrent which is started, downloading or paused
+	StateStarted
+	// StateHashing is a torrent which is being hash checked
+	StateHashing
+	// StateSeeding is a torrent which is active and complete
+	StateSeeding
+)
+
+// String returns a readable name for the state
+func (s TorrentState) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarted:
+		return "started"
+	case StateHashing:
+		return "hashing"
+	case StateSeeding:
+		return "seeding"
+	}
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// DetailedState represents the state of a torrent as shown by most UIs, see RTorrent.DetailedState
+type DetailedState int
+
+const (
+	// DetailedStateStopped is a torrent which is closed, or stopped but still open
+	DetailedStateStopped DetailedState = iota
+	// DetailedStateQueued is a torrent which is started and open but not active (paused or waiting)
+	DetailedStateQueued
+	// DetailedStateChecking is a torrent which is being hash checked
+	DetailedStateChecking
+	// DetailedStateDownloading is an active torrent which is not complete
+	DetailedStateDownloading
+	// DetailedStateSeeding is an active torrent which is complete
+	DetailedStateSeeding
+	// DetailedStateErrored is a torrent which reports a message, usually a tracker error
+	DetailedStateErrored
+)
+
+// String returns a readable name for the state
+func (s DetailedState) String() string {
+	switch s {
+	case DetailedStateStopped:
+		return "stopped"
+	case DetailedStateQueued:
+		return "queued"
+	case DetailedStateChecking:
+		return "checking"
+	case DetailedStateDownloading:
+		return "downloading"
+	case DetailedStateSeeding:
+		return "seeding"
+	case DetailedStateErrored:
+		return "errored"
+	}
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// GlobalStats is an overview of a rTorrent instance, see RTorrent.GlobalStats
+type GlobalStats struct {
+	Hostname  string `json:"hostname"`
+	IP        string `json:"ip"`
+	DownTotal int64  `json:"down_total"`
+	UpTotal   int64  `json:"up_total"`
+	DownRate  int    `json:"down_rate"`
+	UpRate    int    `json:"up_rate"`
+	Torrents  int    `json:"torrents"`
+}
+
+// Versions are the versions of rTorrent and of the libtorrent it is built with, see RTorrent.Versions
+type Versions struct {
+	Client  string `json:"client"`
+	Library string `json:"library"`
+}
+
+// StatsSnapshot holds the global transfer totals at a point in time, see RTorrent.Snapshot and RateBetween
+type StatsSnapshot struct {
+	Time      time.Time `json:"time"`
+	DownTotal int64     `json:"down_total"`
+	UpTotal   int64     `json:"up_total"`
+}
+
+// RateBetween returns the average download and upload rates (bytes/s) between two snapshots
+// Zero rates are returned when no time elapsed between the snapshots, and for a total which went down since
+// the previous snapshot, which happens when rTorrent restarted in between.
+func RateBetween(prev, curr StatsSnapshot) (downRate, upRate float64) {
+	elapsed := curr.Time.Sub(prev.Time).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	if delta := curr.DownTotal - prev.DownTotal; delta > 0 {
+		downRate = float64(delta) / elapsed
+	}
+	if delta := curr.UpTotal - prev.UpTotal; delta > 0 {
+		upRate = float64(delta) / elapsed
+	}
+	return downRate, upRate
 }
 
 // File represents a file in rTorrent
 type File struct {
-	Path string
-	Size int
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Priority is the download priority of the file, see FilePriorityOff, FilePriorityNormal and FilePriorityHigh
+	Priority int `json:"priority"`
+	// CompletedChunks and SizeChunks are the number of chunks of the file which are complete and in total
+	// Chunks at the boundaries of files are shared, so they are counted for each of the files they belong to.
+	CompletedChunks int `json:"completed_chunks"`
+	SizeChunks      int `json:"size_chunks"`
+}
+
+// PercentComplete returns how much of the file is complete, from 0 to 100, computed from its chunks
+// A file without chunks, e.g. an empty file, is 100% complete.
+func (f *File) PercentComplete() float64 {
+	if f.SizeChunks <= 0 {
+		return 100
+	}
+	return float64(f.CompletedChunks) * 100 / float64(f.SizeChunks)
+}
+
+// File priorities, as reported by f.priority
+const (
+	// FilePriorityOff is a file which is not downloaded
+	FilePriorityOff = 0
+	// FilePriorityNormal is a file downloaded with a normal priority, the default
+	FilePriorityNormal = 1
+	// FilePriorityHigh is a file downloaded with a high priority
+	FilePriorityHigh = 2
+)
+
+// TrackerType represents the protocol of a tracker, as reported by t.type
+type TrackerType int
+
+const (
+	// TrackerHTTP is a HTTP(S) tracker
+	TrackerHTTP TrackerType = 1
+	// TrackerUDP is a UDP tracker
+	TrackerUDP TrackerType = 2
+	// TrackerDHT is the DHT pseudo tracker
+	TrackerDHT TrackerType = 3
+)
+
+// Tracker represents a tracker of a torrent in rTorrent
+type Tracker struct {
+	URL     string      `json:"url"`
+	Type    TrackerType `json:"type"`
+	Enabled bool        `json:"enabled"`
+	// ScrapeComplete and ScrapeIncomplete are the number of seeders and leechers reported by the last scrape
+	ScrapeComplete   int `json:"scrape_complete"`
+	ScrapeIncomplete int `json:"scrape_incomplete"`
+	// MinInterval is the minimum time the tracker asks clients to wait between announces
+	// It is marshalled to JSON in whole seconds as min_interval_seconds.
+	MinInterval time.Duration `json:"-"`
+	// NextAnnounce is when the next announce is scheduled, the zero time ("0001-01-01T00:00:00Z" in JSON) when none is
+	NextAnnounce time.Time `json:"next_announce"`
+}
+
+// trackerJSON is the JSON representation of a Tracker
+type trackerJSON struct {
+	tracker
+	MinIntervalSeconds int64 `json:"min_interval_seconds"`
+}
+
+// tracker has the fields of Tracker without its methods, so they can be marshalled without recursion
+type tracker Tracker
+
+// MarshalJSON marshals the tracker, with MinInterval in seconds
+func (t Tracker) MarshalJSON() ([]byte, error) {
+	return json.Marshal(trackerJSON{tracker(t), int64(t.MinInterval / time.Second)})
+}
+
+// UnmarshalJSON unmarshals a tracker marshalled by MarshalJSON
+func (t *Tracker) UnmarshalJSON(data []byte) error {
+	var v trackerJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = Tracker(v.tracker)
+	t.MinInterval = time.Duration(v.MinIntervalSeconds) * time.Second
+	return nil
+}
+
+// Peer represents a peer connected to a torrent in rTorrent
+type Peer struct {
+	Address       string `json:"address"`
+	Port          int    `json:"port"`
+	ClientVersion string `json:"client_version"`
+	// DownRate and UpRate are the transfer rates from and to the peer (bytes/s)
+	DownRate int `json:"down_rate"`
+	UpRate   int `json:"up_rate"`
+	// CompletedPercent is how much of the torrent the peer has, from 0 to 100
+	CompletedPercent int  `json:"completed_percent"`
+	Encrypted        bool `json:"encrypted"`
 }
 
 // Field represents a attribute on a RTorrent entity that can be queried or set
@@ -73,6 +442,14 @@ const (
 	DName Field = "d.name"
 	// DLabel represents the label of a "Downloading Item"
 	DLabel Field = "d.custom1"
+	// DCustom2 represents the second custom field of a "Downloading Item"
+	DCustom2 Field = "d.custom2"
+	// DCustom3 represents the third custom field of a "Downloading Item"
+	DCustom3 Field = "d.custom3"
+	// DCustom4 represents the fourth custom field of a "Downloading Item"
+	DCustom4 Field = "d.custom4"
+	// DCustom5 represents the fifth custom field of a "Downloading Item"
+	DCustom5 Field = "d.custom5"
 	// DSizeInBytes represents the size in bytes of a "Downloading Item"
 	DSizeInBytes Field = "d.size_bytes"
 	// DHash represents the hash of a "Downloading Item"
@@ -99,11 +476,89 @@ const (
 	DFinishedTime Field = "d.timestamp.finished"
 	// DStartedTime represents the date the torrent started downloading
 	DStartedTime Field = "d.timestamp.started"
+	// DLoadDate represents the date the torrent was added to rTorrent
+	DLoadDate Field = "d.load_date"
+	// DThrottleName represents the name of the throttle group of a "Downloading Item", empty when it has none
+	DThrottleName Field = "d.throttle_name"
+	// DUpTotal represents the total bytes uploaded for the "Downloading Item"
+	DUpTotal Field = "d.up.total"
+	// DDownTotal represents the total bytes downloaded for the "Downloading Item"
+	DDownTotal Field = "d.down.total"
+	// DCompletedChunks represents the number of completed chunks of the "Downloading Item"
+	DCompletedChunks Field = "d.completed_chunks"
+	// DSizeChunks represents the total number of chunks of the "Downloading Item"
+	DSizeChunks Field = "d.size_chunks"
+	// DChunkSize represents the size in bytes of the chunks (pieces) of the "Downloading Item"
+	DChunkSize Field = "d.chunk_size"
+	// DIsOpen represents whether a "Downloading Item" is open or not
+	DIsOpen Field = "d.is_open"
+	// DHashing represents whether a "Downloading Item" is being hash checked (0 when it isn't)
+	DHashing Field = "d.hashing"
+	// DMessage represents the last message (usually an error) reported for a "Downloading Item"
+	DMessage Field = "d.message"
+	// DPriority represents the priority of a "Downloading Item", see Priority
+	DPriority Field = "d.priority"
+	// DIsMultiFile represents whether a "Downloading Item" has multiple files (stored in a directory named after it)
+	DIsMultiFile Field = "d.is_multi_file"
+	// DIsMeta represents whether a "Downloading Item" is a magnet link whose metadata is not retrieved yet
+	DIsMeta Field = "d.is_meta"
+	// DState represents whether a "Downloading Item" is started (1) or stopped (0)
+	DState Field = "d.state"
+	// DPeersConnected represents the number of peers connected to a "Downloading Item"
+	DPeersConnected Field = "d.peers_connected"
+	// DPeersComplete represents the number of connected peers of a "Downloading Item" which are seeders
+	DPeersComplete Field = "d.peers_complete"
+	// DPeersAccounted represents the number of connected peers of a "Downloading Item" which are leechers
+	DPeersAccounted Field = "d.peers_accounted"
+	// DIsHashChecked represents whether rTorrent considers the data of a "Downloading Item" verified
+	DIsHashChecked Field = "d.is_hash_checked"
+	// DChunksHashed represents the number of chunks hash checked so far while a "Downloading Item" is being hash checked
+	DChunksHashed Field = "d.chunks_hashed"
+	// DSessionFile represents the path of the .torrent file of a "Downloading Item" in the session directory
+	DSessionFile Field = "d.session_file"
 
 	// FPath represents the path of a "File Item"
 	FPath Field = "f.path"
+	// FFrozenPath represents the absolute path of a "File Item", empty until the torrent was opened
+	FFrozenPath Field = "f.frozen_path"
 	// FSizeInBytes represents the size in bytes of a "File Item"
 	FSizeInBytes Field = "f.size_bytes"
+	// FPriority represents the download priority of a "File Item" (0 = off, 1 = normal, 2 = high)
+	FPriority Field = "f.priority"
+	// FCompletedChunks represents the number of completed chunks of a "File Item"
+	FCompletedChunks Field = "f.completed_chunks"
+	// FSizeChunks represents the total number of chunks of a "File Item"
+	FSizeChunks Field = "f.size_chunks"
+
+	// TURL represents the URL of a "Tracker Item"
+	TURL Field = "t.url"
+	// TType represents the protocol of a "Tracker Item", see TrackerType
+	TType Field = "t.type"
+	// TIsEnabled represents whether a "Tracker Item" is enabled or not
+	TIsEnabled Field = "t.is_enabled"
+	// TScrapeComplete represents the number of seeders reported by the last scrape of a "Tracker Item"
+	TScrapeComplete Field = "t.scrape_complete"
+	// TScrapeIncomplete represents the number of leechers reported by the last scrape of a "Tracker Item"
+	TScrapeIncomplete Field = "t.scrape_incomplete"
+	// TMinInterval represents the minimum announce interval requested by a "Tracker Item" (seconds)
+	TMinInterval Field = "t.min_interval"
+	// TActivityTimeNext represents the time of the next announce to a "Tracker Item" (unix timestamp)
+	TActivityTimeNext Field = "t.activity_time_next"
+
+	// PAddress represents the IP address of a "Peer Item"
+	PAddress Field = "p.address"
+	// PPort represents the port of a "Peer Item"
+	PPort Field = "p.port"
+	// PClientVersion represents the client name and version of a "Peer Item"
+	PClientVersion Field = "p.client_version"
+	// PDownRate represents the rate at which data is downloaded from a "Peer Item"
+	PDownRate Field = "p.down_rate"
+	// PUpRate represents the rate at which data is uploaded to a "Peer Item"
+	PUpRate Field = "p.up_rate"
+	// PCompletedPercent represents how much of the torrent a "Peer Item" has (0 to 100)
+	PCompletedPercent Field = "p.completed_percent"
+	// PIsEncrypted represents whether the connection to a "Peer Item" is encrypted
+	PIsEncrypted Field = "p.is_encrypted"
 )
 
 // Query converts the field to a string which allows it to be queried
@@ -129,29 +584,174 @@ func (f *FieldValue) String() string {
 
 // Pretty returns a formatted string representing this Torrent
 func (t *Torrent) Pretty() string {
-	return fmt.Sprintf("Torrent:\n\tHash: %v\n\tName: %v\n\tPath: %v\n\tLabel: %v\n\tSize: %v bytes\n\tCompleted: %v\n\tRatio: %v\n", t.Hash, t.Name, t.Path, t.Label, t.Size, t.Completed, t.Ratio)
+	pretty := fmt.Sprintf("Torrent:\n\tHash: %v\n\tName: %v\n\tPath: %v\n\tLabel: %v\n\tSize: %v bytes\n\tCompleted: %v\n\tComplete: %.1f%%\n\tRatio: %v\n", t.Hash, t.Name, t.Path, t.Label, t.Size, t.Completed, t.PercentComplete(), t.Ratio)
+	if t.Message != "" {
+		pretty += fmt.Sprintf("\tMessage: %v\n", t.Message)
+	}
+	return pretty
 }
 
 // Pretty returns a formatted string representing this File
 func (f *File) Pretty() string {
-	return fmt.Sprintf("File:\n\tPath: %v\n\tSize: %v bytes\n", f.Path, f.Size)
+	return fmt.Sprintf("File:\n\tPath: %v\n\tSize: %v bytes\n\tComplete: %.1f%%\n", f.Path, f.Size, f.PercentComplete())
 }
 
 // New returns a new instance of `RTorrent`
 // Pass in a true value for `insecure` to turn off certificate verification
+// Addresses with the scgi or unix scheme, or the path of a unix socket, are called over SCGI instead of HTTP, see
+// xmlrpc.NewSCGIClient. `insecure` doesn't apply to them.
 func New(addr string, insecure bool) *RTorrent {
+	client := xmlrpc.NewClient(addr, insecure)
+	if xmlrpc.IsSCGIAddr(addr) {
+		client = xmlrpc.NewSCGIClient(addr)
+	}
 	return &RTorrent{
 		addr:         addr,
-		xmlrpcClient: xmlrpc.NewClient(addr, insecure),
+		caller:       client,
+		xmlrpcClient: client,
+		tagDelimiter: DefaultTagDelimiter,
+	}
+}
+
+// Caller makes the XMLRPC calls of RTorrent, xmlrpc.Client implements it
+// Callers which also implement CallContext, like xmlrpc.Client, have the Context variants of the methods abort their
+// requests. For other Callers the context is only checked before each call.
+type Caller interface {
+	Call(name string, args ...interface{}) (interface{}, error)
+}
+
+// contextCaller is a Caller able to abort calls, see Caller
+type contextCaller interface {
+	CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error)
+}
+
+// NewWithCaller returns a new instance of `RTorrent` making its calls with caller, e.g. a fake one returning canned
+// responses in tests. system.multicall requests are made with it too, so it must answer them to use the methods
+// batching calls.
+// The methods configuring the HTTP requests, like WithTimeout or WithHeader, only apply when caller is a
+// *xmlrpc.Client, and Diagnose requires one.
+func NewWithCaller(caller Caller) *RTorrent {
+	client, _ := caller.(*xmlrpc.Client)
+	return &RTorrent{
+		caller:       caller,
+		xmlrpcClient: client,
+		tagDelimiter: DefaultTagDelimiter,
 	}
 }
 
 // WithHTTPClient allows you to a provide a custom http.Client.
 func (r *RTorrent) WithHTTPClient(client *http.Client) *RTorrent {
-	r.xmlrpcClient = xmlrpc.NewClientWithHTTPClient(r.addr, client)
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithHTTPClient(client)
+	}
+	return r
+}
+
+// WithTimeout sets the time limit of XMLRPC requests, there is none by default
+// WithTimeout and WithHTTPClient interact: the last one called wins, as WithHTTPClient replaces the http.Client along
+// with its timeout. Use the Context variants of the methods to limit the time of a single call instead.
+func (r *RTorrent) WithTimeout(timeout time.Duration) *RTorrent {
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithTimeout(timeout)
+	}
+	return r
+}
+
+// WithHTTP1Only makes requests only use HTTP/1.1, for proxies which mishandle HTTP/2, see xmlrpc.Client.WithHTTP1Only
+// Call it after WithHTTPClient, since it applies to the http.Client in use.
+func (r *RTorrent) WithHTTP1Only() *RTorrent {
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithHTTP1Only()
+	}
+	return r
+}
+
+// WithBasicAuth sets the credentials sent with every XMLRPC request, for endpoints behind HTTP basic authentication
+// It can be combined with WithHTTPClient, in any order, and with `insecure`.
+func (r *RTorrent) WithBasicAuth(username, password string) *RTorrent {
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithBasicAuth(username, password)
+	}
+	return r
+}
+
+// WithHeader adds a header which is sent with every XMLRPC request, e.g. an API key required by a proxy.
+// It can be called repeatedly to set multiple headers, see xmlrpc.Client.WithHeader.
+func (r *RTorrent) WithHeader(key, value string) *RTorrent {
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithHeader(key, value)
+	}
+	return r
+}
+
+// WithRetry retries calls failing because of a network error, e.g. a dropped connection, up to attempts times in
+// total. The delay before each retry doubles from backoff, with some jitter. Faults and errors of aborted contexts are
+// returned right away, as retrying them doesn't help.
+// A call which failed after reaching rTorrent may have been executed, so only use it when repeating calls is safe.
+func (r *RTorrent) WithRetry(attempts int, backoff time.Duration) *RTorrent {
+	if rc, ok := r.caller.(*retryCaller); ok {
+		r.caller = rc.caller
+	}
+	if attempts > 1 {
+		r.caller = &retryCaller{caller: r.caller, attempts: attempts, backoff: backoff}
+	}
+	return r
+}
+
+// TransportOptions tune the pooling of the connections to rTorrent, see xmlrpc.TransportOptions
+type TransportOptions = xmlrpc.TransportOptions
+
+// WithTransportOptions tunes the pooling of the connections to rTorrent, see xmlrpc.Client.WithTransportOptions
+// Call it after WithHTTPClient, since it applies to the http.Client in use.
+func (r *RTorrent) WithTransportOptions(options TransportOptions) *RTorrent {
+	if r.xmlrpcClient != nil {
+		r.xmlrpcClient.WithTransportOptions(options)
+	}
+	return r
+}
+
+// WithStartOnAdd sets whether torrents added with AddAuto are started, they are not by default.
+func (r *RTorrent) WithStartOnAdd(start bool) *RTorrent {
+	r.startOnAdd = start
+	return r
+}
+
+// WithTagDelimiter sets the delimiter between the tags stored in the label by SetTags, DefaultTagDelimiter by default.
+func (r *RTorrent) WithTagDelimiter(delimiter string) *RTorrent {
+	r.tagDelimiter = delimiter
 	return r
 }
 
+// WithEraseDataHook sets whether DeleteWithData and CleanupCompleted leave the deletion of the data to the erasedata
+// plugin of ruTorrent instead of running rm on the rTorrent host, disabled by default.
+// The torrents are marked for deletion like ruTorrent does, by setting d.custom5 to "1" before erasing them, which the
+// plugin reacts to. Enable it only when the plugin is installed: otherwise the data is silently left on disk.
+func (r *RTorrent) WithEraseDataHook(enabled bool) *RTorrent {
+	r.eraseDataHook = enabled
+	return r
+}
+
+// AddAuto adds a new torrent, started or not as set with WithStartOnAdd
+// The source is dispatched on its type:
+//  string: a URL (or magnet link), added like Add or AddStopped
+//  []byte: the torrent files data, added like AddTorrent or AddTorrentStopped
+// Any other type is returned as an error. See Add for extraArgs.
+func (r *RTorrent) AddAuto(source interface{}, extraArgs ...*FieldValue) error {
+	switch s := source.(type) {
+	case string:
+		if r.startOnAdd {
+			return r.Add(s, extraArgs...)
+		}
+		return r.AddStopped(s, extraArgs...)
+	case []byte:
+		if r.startOnAdd {
+			return r.AddTorrent(s, extraArgs...)
+		}
+		return r.AddTorrentStopped(s, extraArgs...)
+	}
+	return errors.Errorf("unsupported torrent source type: %T", source)
+}
+
 // AddStopped adds a new torrent by URL in a stopped state
 //
 // extraArgs can be any valid rTorrent rpc command. For instance:
@@ -166,7 +766,12 @@ func (r *RTorrent) WithHTTPClient(client *http.Client) *RTorrent {
 // Or:
 //  AddStopped("some-url", DLabel.SetValue("my-label"), DBasePath.SetValue("/some/valid/path"))
 func (r *RTorrent) AddStopped(url string, extraArgs ...*FieldValue) error {
-	return r.add("load.normal", []byte(url), extraArgs...)
+	return r.AddStoppedContext(context.Background(), url, extraArgs...)
+}
+
+// AddStoppedContext is like AddStopped, the request is aborted when ctx is done
+func (r *RTorrent) AddStoppedContext(ctx context.Context, url string, extraArgs ...*FieldValue) error {
+	return r.addContext(ctx, "load.normal", []byte(url), extraArgs...)
 }
 
 // Add adds a new torrent by URL and starts the torrent
@@ -183,7 +788,71 @@ func (r *RTorrent) AddStopped(url string, extraArgs ...*FieldValue) error {
 // Or:
 //  Add("some-url", DLabel.SetValue("my-label"), DBasePath.SetValue("/some/valid/path"))
 func (r *RTorrent) Add(url string, extraArgs ...*FieldValue) error {
-	return r.add("load.start", []byte(url), extraArgs...)
+	return r.AddContext(context.Background(), url, extraArgs...)
+}
+
+// AddContext is like Add, the request is aborted when ctx is done
+func (r *RTorrent) AddContext(ctx context.Context, url string, extraArgs ...*FieldValue) error {
+	return r.addContext(ctx, "load.start", []byte(url), extraArgs...)
+}
+
+// AddMagnet adds a new torrent by magnet link and starts it, so rTorrent starts retrieving its metadata from peers
+// Until the metadata is retrieved the torrent is listed with the hash of the magnet link but no size and no files,
+// GetFiles returns none for it. See Add for extraArgs.
+func (r *RTorrent) AddMagnet(uri string, extraArgs ...*FieldValue) error {
+	return r.AddMagnetContext(context.Background(), uri, extraArgs...)
+}
+
+// AddMagnetContext is like AddMagnet, the request is aborted when ctx is done
+func (r *RTorrent) AddMagnetContext(ctx context.Context, uri string, extraArgs ...*FieldValue) error {
+	if err := checkMagnet(uri); err != nil {
+		return err
+	}
+	return r.addContext(ctx, "load.start", []byte(uri), extraArgs...)
+}
+
+// AddMagnetStopped adds a new torrent by magnet link in a stopped state
+// The metadata is only retrieved once the torrent is started, see AddMagnet.
+func (r *RTorrent) AddMagnetStopped(uri string, extraArgs ...*FieldValue) error {
+	return r.AddMagnetStoppedContext(context.Background(), uri, extraArgs...)
+}
+
+// AddMagnetStoppedContext is like AddMagnetStopped, the request is aborted when ctx is done
+func (r *RTorrent) AddMagnetStoppedContext(ctx context.Context, uri string, extraArgs ...*FieldValue) error {
+	if err := checkMagnet(uri); err != nil {
+		return err
+	}
+	return r.addContext(ctx, "load.normal", []byte(uri), extraArgs...)
+}
+
+// checkMagnet checks that the URI is a magnet link with a valid BitTorrent info hash (xt=urn:btih:...)
+func checkMagnet(uri string) error {
+	_, err := torrent.MagnetInfoHash(uri)
+	return err
+}
+
+// AddReturningHash adds a new torrent like AddAuto and returns its info hash, which identifies it in rTorrent
+// The hash is known before adding: it is computed locally from the torrent files data, or read from the magnet link.
+// Other URLs aren't supported since the torrent is only known once rTorrent fetched it.
+func (r *RTorrent) AddReturningHash(source interface{}, extraArgs ...*FieldValue) (string, error) {
+	var hash string
+	var err error
+	switch s := source.(type) {
+	case string:
+		hash, err = torrent.MagnetInfoHash(s)
+	case []byte:
+		hash, err = torrent.InfoHash(s)
+		err = errors.Wrap(err, "failed to compute info hash")
+	default:
+		return "", errors.Errorf("unsupported torrent source type: %T", source)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := r.AddAuto(source, extraArgs...); err != nil {
+		return "", err
+	}
+	return hash, nil
 }
 
 // AddTorrentStopped adds a new torrent by the torrent files data but does not start the torrent
@@ -200,7 +869,12 @@ func (r *RTorrent) Add(url string, extraArgs ...*FieldValue) error {
 // Or:
 //  AddTorrentStopped(fileData, DLabel.SetValue("my-label"), DBasePath.SetValue("/some/valid/path"))
 func (r *RTorrent) AddTorrentStopped(data []byte, extraArgs ...*FieldValue) error {
-	return r.add("load.raw", data, extraArgs...)
+	return r.AddTorrentStoppedContext(context.Background(), data, extraArgs...)
+}
+
+// AddTorrentStoppedContext is like AddTorrentStopped, the request is aborted when ctx is done
+func (r *RTorrent) AddTorrentStoppedContext(ctx context.Context, data []byte, extraArgs ...*FieldValue) error {
+	return r.addContext(ctx, "load.raw", data, extraArgs...)
 }
 
 // AddTorrent adds a new torrent by the torrent files data and starts the torrent
@@ -217,363 +891,2392 @@ func (r *RTorrent) AddTorrentStopped(data []byte, extraArgs ...*FieldValue) erro
 // Or:
 //  AddTorrent(fileData, DLabel.SetValue("my-label"), DBasePath.SetValue("/some/valid/path"))
 func (r *RTorrent) AddTorrent(data []byte, extraArgs ...*FieldValue) error {
-	return r.add("load.raw_start", data, extraArgs...)
+	return r.AddTorrentContext(context.Background(), data, extraArgs...)
 }
 
-func (r *RTorrent) add(cmd string, data []byte, extraArgs ...*FieldValue) error {
-	args := []interface{}{data}
-	for _, v := range extraArgs {
-		args = append(args, v.String())
-	}
-
-	_, err := r.xmlrpcClient.Call(cmd, "", args)
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("%s XMLRPC call failed", cmd))
-	}
-	return nil
+// AddTorrentContext is like AddTorrent, the request is aborted when ctx is done
+func (r *RTorrent) AddTorrentContext(ctx context.Context, data []byte, extraArgs ...*FieldValue) error {
+	return r.addContext(ctx, "load.raw_start", data, extraArgs...)
 }
 
-// IP returns the IP reported by this RTorrent instance
-func (r *RTorrent) IP() (string, error) {
-	result, err := r.xmlrpcClient.Call("network.bind_address")
+// AddTorrentChecked adds a new torrent by the torrent files data after verifying its info hash
+// The info hash is computed locally and compared case-insensitively to expectedHash; the torrent is not added when they differ.
+// Pass in a true value for `start` to start the torrent once added.
+func (r *RTorrent) AddTorrentChecked(data []byte, expectedHash string, start bool, extraArgs ...*FieldValue) error {
+	hash, err := torrent.InfoHash(data)
 	if err != nil {
-		return "", errors.Wrap(err, "network.bind_address XMLRPC call failed")
+		return errors.Wrap(err, "failed to compute info hash")
 	}
-	if ips, ok := result.([]interface{}); ok {
-		result = ips[0]
+	if !strings.EqualFold(hash, expectedHash) {
+		return errors.Errorf("info hash mismatch: expected %s, got %s", expectedHash, hash)
 	}
-	if ip, ok := result.(string); ok {
-		return ip, nil
+	if start {
+		return r.AddTorrent(data, extraArgs...)
 	}
-	return "", errors.Errorf("result isn't string: %v", result)
+	return r.AddTorrentStopped(data, extraArgs...)
 }
 
-// Name returns the name reported by this RTorrent instance
-func (r *RTorrent) Name() (string, error) {
-	result, err := r.xmlrpcClient.Call("system.hostname")
+// AddFile adds a new torrent from the .torrent file at path on the local filesystem
+// Pass in a true value for `start` to start the torrent once added. The file is checked to be a valid torrent before
+// it is sent to rTorrent. See AddTorrent for extraArgs.
+func (r *RTorrent) AddFile(path string, start bool, extraArgs ...*FieldValue) error {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", errors.Wrap(err, "system.hostname XMLRPC call failed")
+		return errors.Wrap(err, "failed to read torrent file")
 	}
-	if names, ok := result.([]interface{}); ok {
-		result = names[0]
+	if _, err := torrent.InfoHash(data); err != nil {
+		return errors.Wrapf(err, "%s is not a valid torrent file", path)
 	}
-	if name, ok := result.(string); ok {
-		return name, nil
+	if start {
+		return r.AddTorrent(data, extraArgs...)
 	}
-	return "", errors.Errorf("result isn't string: %v", result)
+	return r.AddTorrentStopped(data, extraArgs...)
 }
 
-// DownTotal returns the total downloaded metric reported by this RTorrent instance (bytes)
-func (r *RTorrent) DownTotal() (int, error) {
-	result, err := r.xmlrpcClient.Call("throttle.global_down.total")
+// ReAddWithData adds the torrent files data (stopped) pointing at data which already exists in directory
+// directory is the directory containing the data, like the default download directory: for multi-file torrents rTorrent
+// looks for a sub directory named after the torrent. Unless skipHashCheck is true a hash check is triggered right away.
+//
+// Skipping the hash check only saves time when the torrent data carries fast-resume information (libtorrent_resume),
+// e.g. when it was exported from a session directory. Without it rTorrent considers nothing downloaded and will hash
+// check the data anyway when the torrent is started.
+func (r *RTorrent) ReAddWithData(data []byte, directory string, skipHashCheck bool) error {
+	hash, err := torrent.InfoHash(data)
 	if err != nil {
-		return 0, errors.Wrap(err, "throttle.global_down.total XMLRPC call failed")
+		return errors.Wrap(err, "failed to compute info hash")
 	}
-	if totals, ok := result.([]interface{}); ok {
-		result = totals[0]
+	if err := r.AddTorrentStopped(data, DDirectory.SetValue(directory)); err != nil {
+		return err
 	}
-	if total, ok := result.(int); ok {
-		return total, nil
+	if skipHashCheck {
+		return nil
 	}
-	return 0, errors.Errorf("result isn't int: %v", result)
+	return r.CheckHash(Torrent{Hash: hash})
 }
 
-// DownRate returns the current download rate reported by this RTorrent instance (bytes/s)
-func (r *RTorrent) DownRate() (int, error) {
-	result, err := r.xmlrpcClient.Call("throttle.global_down.rate")
-	if err != nil {
-		return 0, errors.Wrap(err, "throttle.global_down.rate XMLRPC call failed")
-	}
-	if totals, ok := result.([]interface{}); ok {
-		result = totals[0]
-	}
-	if total, ok := result.(int); ok {
-		return total, nil
+func (r *RTorrent) addContext(ctx context.Context, cmd string, data []byte, extraArgs ...*FieldValue) error {
+	args := []interface{}{data}
+	for _, v := range extraArgs {
+		args = append(args, v.String())
 	}
-	return 0, errors.Errorf("result isn't int: %v", result)
-}
 
-// UpTotal returns the total uploaded metric reported by this RTorrent instance (bytes)
-func (r *RTorrent) UpTotal() (int, error) {
-	result, err := r.xmlrpcClient.Call("throttle.global_up.total")
+	_, err := r.callContext(ctx, cmd, "", args)
 	if err != nil {
-		return 0, errors.Wrap(err, "throttle.global_up.total XMLRPC call failed")
-	}
-	if totals, ok := result.([]interface{}); ok {
-		result = totals[0]
-	}
-	if total, ok := result.(int); ok {
-		return total, nil
+		if strings.HasPrefix(cmd, "load.raw") {
+			// rTorrent either faults or drops the connection on requests above its size limit, so check the limit
+			// once the call failed to turn either into an actionable error
+			if limit, limitErr := r.XMLRPCSizeLimit(); limitErr == nil && limit > 0 && base64.StdEncoding.EncodedLen(len(data)) > limit {
+				return errors.Wrapf(ErrRequestTooLarge, "torrent data of %d bytes exceeds network.xmlrpc.size_limit of %d bytes (base64 encoded), "+
+					"raise the limit or add the torrent by URL with Add or AddStopped (load.normal) instead", len(data), limit)
+			}
+		}
+		return errors.Wrap(err, fmt.Sprintf("%s XMLRPC call failed", cmd))
 	}
-	return 0, errors.Errorf("result isn't int: %v", result)
+	return nil
 }
 
-// UpRate returns the current upload rate reported by this RTorrent instance (bytes/s)
-func (r *RTorrent) UpRate() (int, error) {
-	result, err := r.xmlrpcClient.Call("throttle.global_up.rate")
-	if err != nil {
-		return 0, errors.Wrap(err, "throttle.global_up.rate XMLRPC call failed")
-	}
-	if totals, ok := result.([]interface{}); ok {
-		result = totals[0]
-	}
-	if total, ok := result.(int); ok {
-		return total, nil
-	}
-	return 0, errors.Errorf("result isn't int: %v", result)
+// XMLRPCSizeLimit returns the maximum size of a XMLRPC request accepted by this RTorrent instance (bytes)
+func (r *RTorrent) XMLRPCSizeLimit() (int, error) {
+	return r.callInt("network.xmlrpc.size_limit")
 }
 
-// GetTorrents returns all of the torrents reported by this RTorrent instance
-func (r *RTorrent) GetTorrents(view View) ([]Torrent, error) {
-	args := []interface{}{"", string(view), DName.Query(), DSizeInBytes.Query(), DHash.Query(), DLabel.Query(), DDirectory.Query(), DIsActive.Query(), DComplete.Query(), DRatio.Query(), DCreationTime.Query(), DFinishedTime.Query(), DStartedTime.Query()}
-	results, err := r.xmlrpcClient.Call("d.multicall2", args...)
-	var torrents []Torrent
-	if err != nil {
-		return torrents, errors.Wrap(err, "d.multicall2 XMLRPC call failed")
-	}
-	for _, outerResult := range results.([]interface{}) {
-		for _, innerResult := range outerResult.([]interface{}) {
-			torrentData := innerResult.([]interface{})
-			torrents = append(torrents, Torrent{
-				Hash:      torrentData[2].(string),
-				Name:      torrentData[0].(string),
-				Path:      torrentData[4].(string),
-				Size:      torrentData[1].(int),
-				Label:     torrentData[3].(string),
-				Completed: torrentData[6].(int) > 0,
-				Ratio:     float64(torrentData[7].(int)) / float64(1000),
-				Created:   time.Unix(int64(torrentData[8].(int)), 0),
-				Finished:  time.Unix(int64(torrentData[9].(int)), 0),
-				Started:   time.Unix(int64(torrentData[10].(int)), 0),
-			})
-		}
+// Diagnostics tells which stage of reaching rTorrent failed, see xmlrpc.Diagnostics
+type Diagnostics = xmlrpc.Diagnostics
+
+// Diagnose checks whether rTorrent can be reached, stage by stage: DNS, TCP, TLS, HTTP and XMLRPC
+// It calls system.client_version, which has no side effect, and each stage times out after a few seconds.
+// Failed stages are reported in the Diagnostics, the returned error is only about an invalid endpoint.
+func (r *RTorrent) Diagnose() (Diagnostics, error) {
+	if r.xmlrpcClient == nil {
+		return Diagnostics{}, errors.New("diagnose requires a xmlrpc.Client caller")
 	}
-	return torrents, nil
+	return r.xmlrpcClient.Diagnose("system.client_version")
 }
 
-// GetTorrent returns the torrent identified by the given hash
-func (r *RTorrent) GetTorrent(hash string) (Torrent, error) {
-	var t Torrent
-	t.Hash = hash
-	// Name
-	results, err := r.xmlrpcClient.Call("d.name", t.Hash)
-	if err != nil {
-		return t, errors.Wrap(err, "d.name XMLRPC call failed")
+// IP returns the IP reported by this RTorrent instance
+func (r *RTorrent) IP() (string, error) {
+	return r.callString("network.bind_address")
+}
+
+// SetBindAddress sets the IP address rTorrent binds its sockets to (network.bind_address)
+// Existing peer connections are not affected, only connections opened afterwards use the new address.
+func (r *RTorrent) SetBindAddress(addr string) error {
+	if net.ParseIP(addr) == nil {
+		return errors.Errorf("invalid IP address: %q", addr)
 	}
-	t.Name = results.([]interface{})[0].(string)
-	// Size
-	results, err = r.xmlrpcClient.Call("d.size_bytes", t.Hash)
-	if err != nil {
-		return t, errors.Wrap(err, "d.size_bytes XMLRPC call failed")
+	return r.callDiscard("network.bind_address.set", "", addr)
+}
+
+// SetLocalAddress sets the address rTorrent reports to trackers (network.local_address), an IP or a host name
+// It only changes what is announced, the sockets stay bound to the bind address. Takes effect on the next announce.
+// Unlike SetBindAddress a host name, e.g. a dynamic DNS name, is accepted: trackers resolve it themselves.
+func (r *RTorrent) SetLocalAddress(addr string) error {
+	if addr == "" || strings.ContainsAny(addr, " \t\r\n/") {
+		return errors.Errorf("invalid address: %q", addr)
 	}
-	t.Size = results.([]interface{})[0].(int)
-	// Label
-	results, err = r.xmlrpcClient.Call("d.custom1", t.Hash)
+	return r.callDiscard("network.local_address.set", "", addr)
+}
+
+// Name returns the name reported by this RTorrent instance
+func (r *RTorrent) Name() (string, error) {
+	return r.callString("system.hostname")
+}
+
+// ClientVersion returns the version of rTorrent, e.g. "0.9.8" (system.client_version)
+func (r *RTorrent) ClientVersion() (string, error) {
+	return r.callString("system.client_version")
+}
+
+// LibraryVersion returns the version of libtorrent rTorrent is built with, e.g. "0.13.8" (system.library_version)
+func (r *RTorrent) LibraryVersion() (string, error) {
+	return r.callString("system.library_version")
+}
+
+// Versions returns the versions of rTorrent and libtorrent in a single call
+func (r *RTorrent) Versions() (Versions, error) {
+	results, err := r.multicall(
+		call{"system.client_version", []interface{}{""}},
+		call{"system.library_version", []interface{}{""}},
+	)
 	if err != nil {
-		return t, errors.Wrap(err, "d.custom1 XMLRPC call failed")
+		return Versions{}, err
 	}
-	t.Label = results.([]interface{})[0].(string)
-	// Path
-	results, err = r.xmlrpcClient.Call("d.directory", t.Hash)
+	return Versions{Client: asString(results[0]), Library: asString(results[1])}, nil
+}
+
+// APIVersion returns the version of the XMLRPC API of rTorrent (system.api_version), e.g. "10"
+// rTorrent builds older than 0.9.7 lack the command, an empty string is returned for them instead of an error.
+func (r *RTorrent) APIVersion() (string, error) {
+	result, err := r.callValue("system.api_version")
 	if err != nil {
-		return t, errors.Wrap(err, "d.directory XMLRPC call failed")
+		if isMethodNotFound(err) {
+			return "", nil
+		}
+		return "", err
 	}
-	t.Path = results.([]interface{})[0].(string)
-	// Completed
-	results, err = r.xmlrpcClient.Call("d.complete", t.Hash)
-	if err != nil {
-		return t, errors.Wrap(err, "d.complete XMLRPC call failed")
+	// the version is an integer on some builds
+	switch v := result.(type) {
+	case string:
+		return v, nil
+	case int, int64:
+		return fmt.Sprint(v), nil
 	}
-	t.Completed = results.([]interface{})[0].(int) > 0
-	// Ratio
-	results, err = r.xmlrpcClient.Call("d.ratio", t.Hash)
+	return "", errors.Errorf("system.api_version result isn't string: %v", result)
+}
+
+// SupportsMethod checks if rTorrent knows the command, e.g. "d.multicall2", from system.listMethods
+// It allows falling back to other commands on older builds. The list is fetched on every call.
+func (r *RTorrent) SupportsMethod(name string) (bool, error) {
+	result, err := r.callValue("system.listMethods")
 	if err != nil {
-		return t, errors.Wrap(err, "d.ratio XMLRPC call failed")
+		return false, err
 	}
-	t.Ratio = float64(results.([]interface{})[0].(int)) / float64(1000)
-	// Created
-	results, err = r.xmlrpcClient.Call(string(DCreationTime), t.Hash)
-	if err != nil {
-		return t, errors.Wrap(err, fmt.Sprintf("%s XMLRPC call failed", string(DCreationTime)))
+	methods, ok := result.([]interface{})
+	if !ok {
+		return false, errors.Errorf("system.listMethods result isn't a list: %v", result)
 	}
-	t.Created = time.Unix(int64(results.([]interface{})[0].(int)), 0)
-	// Finished
-	results, err = r.xmlrpcClient.Call(string(DFinishedTime), t.Hash)
-	if err != nil {
-		return t, errors.Wrap(err, fmt.Sprintf("%s XMLRPC call failed", string(DFinishedTime)))
+	for _, method := range methods {
+		if asString(method) == name {
+			return true, nil
+		}
 	}
-	t.Finished = time.Unix(int64(results.([]interface{})[0].(int)), 0)
-	// Started
-	results, err = r.xmlrpcClient.Call(string(DStartedTime), t.Hash)
+	return false, nil
+}
+
+// ServerTime returns the current time of the rTorrent host (system.time_seconds)
+// The resolution is one second, compare it with the local clock to detect clock skew.
+func (r *RTorrent) ServerTime() (time.Time, error) {
+	seconds, err := r.callInt64("system.time_seconds")
 	if err != nil {
-		return t, errors.Wrap(err, fmt.Sprintf("%s XMLRPC call failed", string(DStartedTime)))
+		return time.Time{}, err
 	}
-	t.Created = time.Unix(int64(results.([]interface{})[0].(int)), 0)
+	return time.Unix(seconds, 0), nil
+}
 
-	return t, nil
+// PID returns the process ID of this RTorrent instance (system.pid)
+func (r *RTorrent) PID() (int, error) {
+	return r.callInt("system.pid")
 }
 
-// Delete removes the torrent
-func (r *RTorrent) Delete(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.erase", t.Hash)
+// StartupTime returns when this RTorrent instance was started (system.startup_time)
+func (r *RTorrent) StartupTime() (time.Time, error) {
+	seconds, err := r.callInt64("system.startup_time")
 	if err != nil {
-		return errors.Wrap(err, "d.erase XMLRPC call failed")
+		return time.Time{}, err
 	}
-	return nil
+	return time.Unix(seconds, 0), nil
 }
 
-// GetFiles returns all of the files for a given `Torrent`
-func (r *RTorrent) GetFiles(t Torrent) ([]File, error) {
-	args := []interface{}{t.Hash, 0, FPath.Query(), FSizeInBytes.Query()}
-	results, err := r.xmlrpcClient.Call("f.multicall", args...)
-	var files []File
+// ServerIdentity returns an identifier of the running rTorrent process, formatted as "<pid>-<startup time>"
+// with the startup time as a unix timestamp, e.g. "1234-1635781106". It changes whenever rTorrent is restarted:
+// the startup time differs even when the new process gets the same PID. Compare the identity between polls to
+// detect restarts, which reset everything rTorrent does not persist (e.g. the global transfer totals).
+func (r *RTorrent) ServerIdentity() (string, error) {
+	results, err := r.multicall(
+		call{"system.pid", []interface{}{""}},
+		call{"system.startup_time", []interface{}{""}},
+	)
 	if err != nil {
-		return files, errors.Wrap(err, "f.multicall XMLRPC call failed")
-	}
-	for _, outerResult := range results.([]interface{}) {
-		for _, innerResult := range outerResult.([]interface{}) {
-			fileData := innerResult.([]interface{})
-			files = append(files, File{
-				Path: fileData[0].(string),
-				Size: fileData[1].(int),
-			})
-		}
+		return "", err
 	}
-	return files, nil
+	return fmt.Sprintf("%d-%d", asInt(results[0]), asInt(results[1])), nil
 }
 
-// SetLabel sets the label on the given Torrent
-func (r *RTorrent) SetLabel(t Torrent, newLabel string) error {
-	t.Label = newLabel
-	args := []interface{}{t.Hash, newLabel}
-	if _, err := r.xmlrpcClient.Call("d.custom1.set", args...); err != nil {
-		return errors.Wrap(err, "d.custom1.set XMLRPC call failed")
+// SetDHTMode sets the DHT mode, one of "disable", "off", "auto" or "on"
+// "auto" starts DHT when a torrent without trackers needs it, "disable" prevents it from ever being started.
+func (r *RTorrent) SetDHTMode(mode string) error {
+	switch mode {
+	case "disable", "off", "auto", "on":
+	default:
+		return errors.Errorf("invalid DHT mode: %q", mode)
 	}
-	return nil
+	return r.callDiscard("dht.mode.set", "", mode)
 }
 
-// GetStatus returns the Status for a given Torrent
+// PeerExchange returns whether peer exchange (PEX) is enabled
+func (r *RTorrent) PeerExchange() (bool, error) {
+	return r.callBool("protocol.pex")
+}
+
+// SetPeerExchange enables or disables peer exchange (PEX)
+// Torrents flagged as private never use PEX regardless of this setting.
+func (r *RTorrent) SetPeerExchange(enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return r.callDiscard("protocol.pex.set", "", value)
+}
+
+// SetUDPTrackers enables or disables announcing to UDP trackers (trackers.use_udp)
+// When disabled, udp:// trackers are skipped and only HTTP trackers are announced to.
+func (r *RTorrent) SetUDPTrackers(enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return r.callDiscard("trackers.use_udp.set", "", value)
+}
+
+// DefaultUploadSlots returns the number of upload slots given to newly added torrents (throttle.max_uploads)
+func (r *RTorrent) DefaultUploadSlots() (int, error) {
+	return r.callInt("throttle.max_uploads")
+}
+
+// SetDefaultUploadSlots sets the number of upload slots given to newly added torrents (throttle.max_uploads)
+// Torrents which were already added keep their own value (d.uploads_max). This is unrelated to the global cap on
+// the upload slots of all torrents combined (throttle.max_uploads.global), which still applies.
+func (r *RTorrent) SetDefaultUploadSlots(n int) error {
+	if n <= 0 {
+		return errors.Errorf("invalid number of upload slots: %d", n)
+	}
+	return r.callDiscard("throttle.max_uploads.set", "", n)
+}
+
+// encryptionOptions are the options accepted by protocol.encryption.set
+var encryptionOptions = map[string]bool{
+	"none":             true,
+	"allow_incoming":   true,
+	"try_outgoing":     true,
+	"require":          true,
+	"require_RC4":      true,
+	"require_rc4":      true,
+	"enable_retry":     true,
+	"prefer_plaintext": true,
+}
+
+// encryptionModeVariable is the variable SetEncryptionMode records the options in, see EncryptionMode
+const encryptionModeVariable = "go_rtorrent.encryption_mode"
+
+// EncryptionMode returns the protocol encryption options last set with SetEncryptionMode, by any client
+// rTorrent only offers protocol.encryption.set, so SetEncryptionMode also records the options in a variable on the
+// server, which is read back. It is empty when the options were only set by the rTorrent configuration, and is
+// forgotten when rTorrent restarts, like the options themselves.
+func (r *RTorrent) EncryptionMode() (string, error) {
+	return r.getVariable(encryptionModeVariable)
+}
+
+// SetEncryptionMode sets the protocol encryption options from a comma separated list
+// e.g. "allow_incoming,try_outgoing,enable_retry" to prefer encryption, or "allow_incoming,require,require_RC4"
+// to only use fully encrypted connections. Options are one of none, allow_incoming, try_outgoing, require,
+// require_RC4, enable_retry and prefer_plaintext, anything else is refused.
+func (r *RTorrent) SetEncryptionMode(mode string) error {
+	args := []interface{}{""}
+	var options []string
+	for _, option := range strings.Split(mode, ",") {
+		option = strings.TrimSpace(option)
+		if !encryptionOptions[option] {
+			return errors.Errorf("invalid encryption option: %q", option)
+		}
+		args = append(args, option)
+		options = append(options, option)
+	}
+	if err := r.callDiscard("protocol.encryption.set", args...); err != nil {
+		return err
+	}
+	if err := r.setVariable(encryptionModeVariable, strings.Join(options, ",")); err != nil {
+		return errors.Wrap(err, "encryption mode set but recording it failed")
+	}
+	return nil
+}
+
+// DownTotal returns the total downloaded metric reported by this RTorrent instance (bytes)
+// rTorrent does not persist this counter, it is reset whenever rTorrent restarts.
+func (r *RTorrent) DownTotal() (int64, error) {
+	return r.callInt64("throttle.global_down.total")
+}
+
+// DownRate returns the current download rate reported by this RTorrent instance (bytes/s)
+func (r *RTorrent) DownRate() (int, error) {
+	return r.callInt("throttle.global_down.rate")
+}
+
+// UpTotal returns the total uploaded metric reported by this RTorrent instance (bytes)
+// rTorrent does not persist this counter, it is reset whenever rTorrent restarts.
+func (r *RTorrent) UpTotal() (int64, error) {
+	return r.callInt64("throttle.global_up.total")
+}
+
+// UpRate returns the current upload rate reported by this RTorrent instance (bytes/s)
+func (r *RTorrent) UpRate() (int, error) {
+	return r.callInt("throttle.global_up.rate")
+}
+
+// SessionDownTotal returns the total downloaded since this RTorrent instance started (bytes)
+// rTorrent only keeps session scoped global totals (throttle.global_down.total), there is no all-time counter.
+// For all-time figures sum the per torrent d.down.total values, which are stored in the session.
+func (r *RTorrent) SessionDownTotal() (int64, error) {
+	return r.DownTotal()
+}
+
+// SessionUpTotal returns the total uploaded since this RTorrent instance started (bytes)
+// rTorrent only keeps session scoped global totals (throttle.global_up.total), there is no all-time counter.
+// For all-time figures sum the per torrent d.up.total values, which are stored in the session.
+func (r *RTorrent) SessionUpTotal() (int64, error) {
+	return r.UpTotal()
+}
+
+// ActiveCounts returns the number of torrents which are currently downloading and seeding
+// Both are read with view.size in a single call: seeding is the size of the "seeding" view, downloading is the
+// size of the "started" view minus the seeding torrents.
+func (r *RTorrent) ActiveCounts() (downloading, seeding int, err error) {
+	results, err := r.multicall(
+		call{"view.size", []interface{}{"", string(ViewStarted)}},
+		call{"view.size", []interface{}{"", string(ViewSeeding)}},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	started, seeding := asInt(results[0]), asInt(results[1])
+	return started - seeding, seeding, nil
+}
+
+// GlobalStats returns the hostname, IP, transfer totals and rates and the number of torrents in a single call
+func (r *RTorrent) GlobalStats() (GlobalStats, error) {
+	results, err := r.multicall(
+		call{"system.hostname", []interface{}{""}},
+		call{"network.bind_address", []interface{}{""}},
+		call{"throttle.global_down.total", []interface{}{""}},
+		call{"throttle.global_up.total", []interface{}{""}},
+		call{"throttle.global_down.rate", []interface{}{""}},
+		call{"throttle.global_up.rate", []interface{}{""}},
+		call{"view.size", []interface{}{"", string(ViewMain)}},
+	)
+	if err != nil {
+		return GlobalStats{}, err
+	}
+	return GlobalStats{
+		Hostname:  asString(results[0]),
+		IP:        asString(results[1]),
+		DownTotal: asInt64(results[2]),
+		UpTotal:   asInt64(results[3]),
+		DownRate:  asInt(results[4]),
+		UpRate:    asInt(results[5]),
+		Torrents:  asInt(results[6]),
+	}, nil
+}
+
+// TotalPeers returns the number of peers connected to all torrents
+// rTorrent has no global counter of connected peers (network.open_sockets also counts other sockets, like the ones
+// of trackers), so the d.peers_connected values of all torrents in the main view are summed from a single
+// d.multicall2 request.
+func (r *RTorrent) TotalPeers() (int, error) {
+	rows, err := r.callList("d.multicall2", "", string(ViewMain), DPeersConnected.Query())
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, result := range rows {
+		if torrentData := asList(result); len(torrentData) > 0 {
+			total += asInt(torrentData[0])
+		}
+	}
+	return total, nil
+}
+
+// Snapshot returns the current global transfer totals, to compute average rates with RateBetween
+// Both totals are read in a single request, Time is set using the local clock.
+func (r *RTorrent) Snapshot() (StatsSnapshot, error) {
+	results, err := r.multicall(
+		call{"throttle.global_down.total", []interface{}{""}},
+		call{"throttle.global_up.total", []interface{}{""}},
+	)
+	if err != nil {
+		return StatsSnapshot{}, err
+	}
+	return StatsSnapshot{
+		Time:      time.Now(),
+		DownTotal: asInt64(results[0]),
+		UpTotal:   asInt64(results[1]),
+	}, nil
+}
+
+// pausedRate is the global rate limit (bytes/s) set by Pause, the lowest limit rTorrent accepts since 0 means unlimited
+const pausedRate = 1
+
+// pausedRatesVariable is the variable Pause stores the replaced limits in as "down,up", empty when not paused
+const pausedRatesVariable = "go_rtorrent.paused_rates"
+
+// Pause halts all transfers by setting the global download and upload limits to 1 byte/s
+// rTorrent has no global pause command, so torrents stay started and connected but transfer next to nothing.
+// The limits in place before are stored in a variable on the server and restored by Resume, so any client can
+// resume, and pausing again while paused does nothing. The variable is lost when rTorrent restarts, along with
+// the paused limits.
+func (r *RTorrent) Pause() error {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	paused, err := r.getVariable(pausedRatesVariable)
+	if err != nil {
+		return err
+	}
+	if paused != "" {
+		return nil
+	}
+	down, up, err := r.globalMaxRates()
+	if err != nil {
+		return err
+	}
+	// stored first, so the limits are never paused without a way to resume
+	if err := r.setVariable(pausedRatesVariable, fmt.Sprintf("%d,%d", down, up)); err != nil {
+		return err
+	}
+	if err := r.setGlobalMaxRates(pausedRate, pausedRate); err != nil {
+		if clearErr := r.setVariable(pausedRatesVariable, ""); clearErr != nil {
+			return errors.Wrapf(err, "pausing failed and so did forgetting the stored limits (%v)", clearErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Resume restores the global download and upload limits stored by Pause, by this or any other client
+// A limit changed while paused, e.g. with SetGlobalDownRate, is kept rather than restored. It returns ErrNotPaused
+// when transfers were not paused with Pause.
+func (r *RTorrent) Resume() error {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	paused, err := r.getVariable(pausedRatesVariable)
+	if err != nil {
+		return err
+	}
+	if paused == "" {
+		return ErrNotPaused
+	}
+	var down, up int
+	if _, err := fmt.Sscanf(paused, "%d,%d", &down, &up); err != nil {
+		return errors.Wrapf(err, "invalid paused limits %q", paused)
+	}
+	currentDown, currentUp, err := r.globalMaxRates()
+	if err != nil {
+		return err
+	}
+	if currentDown != pausedRate {
+		down = currentDown
+	}
+	if currentUp != pausedRate {
+		up = currentUp
+	}
+	if err := r.setGlobalMaxRates(down, up); err != nil {
+		return err
+	}
+	return r.setVariable(pausedRatesVariable, "")
+}
+
+// SetGlobalDownRate sets the global download rate limit (bytes/s), 0 means unlimited
+// A limit set while transfers are paused with Pause is kept by Resume.
+func (r *RTorrent) SetGlobalDownRate(bytesPerSec int) error {
+	return r.setGlobalMaxRate("throttle.global_down.max_rate.set", bytesPerSec)
+}
+
+// SetGlobalUpRate sets the global upload rate limit (bytes/s), 0 means unlimited, see SetGlobalDownRate
+func (r *RTorrent) SetGlobalUpRate(bytesPerSec int) error {
+	return r.setGlobalMaxRate("throttle.global_up.max_rate.set", bytesPerSec)
+}
+
+// GlobalDownMaxRate returns the global download rate limit (bytes/s), 0 means unlimited
+func (r *RTorrent) GlobalDownMaxRate() (int, error) {
+	return r.callInt("throttle.global_down.max_rate")
+}
+
+// GlobalUpMaxRate returns the global upload rate limit (bytes/s), 0 means unlimited
+func (r *RTorrent) GlobalUpMaxRate() (int, error) {
+	return r.callInt("throttle.global_up.max_rate")
+}
+
+func (r *RTorrent) setGlobalMaxRate(cmd string, bytesPerSec int) error {
+	if bytesPerSec < 0 {
+		return errors.Errorf("invalid rate limit: %d", bytesPerSec)
+	}
+	return r.callDiscard(cmd, "", bytesPerSec)
+}
+
+// globalMaxRates returns the global download and upload limits (bytes/s), 0 means unlimited
+func (r *RTorrent) globalMaxRates() (down, up int, err error) {
+	results, err := r.multicall(
+		call{"throttle.global_down.max_rate", []interface{}{""}},
+		call{"throttle.global_up.max_rate", []interface{}{""}},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	return asInt(results[0]), asInt(results[1]), nil
+}
+
+// setGlobalMaxRates sets the global download and upload limits (bytes/s), 0 means unlimited
+func (r *RTorrent) setGlobalMaxRates(down, up int) error {
+	_, err := r.multicall(
+		call{"throttle.global_down.max_rate.set", []interface{}{"", down}},
+		call{"throttle.global_up.max_rate.set", []interface{}{"", up}},
+	)
+	return err
+}
+
+// Throttle groups used by the seeding and leeching rate limits
+// rTorrent does not assign torrents to these groups itself: a torrent only uses a group once its d.throttle_name is
+// set to the group name, e.g. with d.throttle_name.set from an event handler in the rTorrent configuration.
+const (
+	ThrottleGroupSeed  = "seed"
+	ThrottleGroupLeech = "leech"
+)
+
+// SetSeedingRateLimit sets the upload rate limit (bytes/s) of the ThrottleGroupSeed throttle group, 0 means unlimited
+// Group limits apply within the global caps (throttle.global_up.max_rate): the global cap still limits the combined
+// rate of all torrents. rTorrent works in whole KiB/s, non-zero limits are rounded up to the next KiB.
+func (r *RTorrent) SetSeedingRateLimit(bytesPerSec int) error {
+	return r.setThrottle("throttle.up", ThrottleGroupSeed, bytesPerSec)
+}
+
+// SeedingRateLimit returns the upload rate limit (bytes/s) of the ThrottleGroupSeed throttle group, 0 means unlimited
+func (r *RTorrent) SeedingRateLimit() (int, error) {
+	return r.throttleMax("throttle.up.max", ThrottleGroupSeed)
+}
+
+// SetLeechingRateLimit sets the download rate limit (bytes/s) of the ThrottleGroupLeech throttle group, 0 means unlimited
+// Group limits apply within the global caps (throttle.global_down.max_rate): the global cap still limits the combined
+// rate of all torrents. rTorrent works in whole KiB/s, non-zero limits are rounded up to the next KiB.
+func (r *RTorrent) SetLeechingRateLimit(bytesPerSec int) error {
+	return r.setThrottle("throttle.down", ThrottleGroupLeech, bytesPerSec)
+}
+
+// LeechingRateLimit returns the download rate limit (bytes/s) of the ThrottleGroupLeech throttle group, 0 means unlimited
+func (r *RTorrent) LeechingRateLimit() (int, error) {
+	return r.throttleMax("throttle.down.max", ThrottleGroupLeech)
+}
+
+// SetThrottleGroup assigns the torrent to the throttle group with the given name (d.throttle_name), "" removes it
+// from its group. rTorrent only changes the group of stopped torrents, so a started torrent is stopped and started
+// again. The limits of the group are set with throttle.up and throttle.down, e.g. with SetSeedingRateLimit for the
+// ThrottleGroupSeed group.
+func (r *RTorrent) SetThrottleGroup(t Torrent, name string) error {
+	results, err := r.multicall(
+		call{DThrottleName.Cmd(), []interface{}{t.Hash}},
+		call{DState.Cmd(), []interface{}{t.Hash}},
+	)
+	if err != nil {
+		return err
+	}
+	if asString(results[0]) == name {
+		return nil
+	}
+	started := asInt(results[1]) == 1
+	if started {
+		if err := r.StopTorrent(t); err != nil {
+			return err
+		}
+	}
+	if err := r.callDiscard("d.throttle_name.set", t.Hash, name); err != nil {
+		if started {
+			_ = r.StartTorrent(t)
+		}
+		return err
+	}
+	if started {
+		return r.StartTorrent(t)
+	}
+	return nil
+}
+
+// ThrottleGroup returns the name of the throttle group of the torrent, "" when it has none
+func (r *RTorrent) ThrottleGroup(t Torrent) (string, error) {
+	return r.callString(DThrottleName.Cmd(), t.Hash)
+}
+
+// SetDownloadRate sets the download rate limit (bytes/s) of the torrent, 0 means unlimited
+// rTorrent only limits throttle groups, so the torrent is assigned to a group of its own named after its hash, see
+// SetThrottleGroup. A torrent which was in another group, like ThrottleGroupLeech, leaves it.
+// Like group limits, the rate is rounded up to the next KiB and the global caps still apply.
+func (r *RTorrent) SetDownloadRate(t Torrent, bytesPerSec int) error {
+	return r.setTorrentRate(t, "throttle.down", bytesPerSec)
+}
+
+// DownloadRate returns the download rate limit (bytes/s) of the throttle group of the torrent, 0 means unlimited
+func (r *RTorrent) DownloadRate(t Torrent) (int, error) {
+	return r.torrentRate(t, "throttle.down.max")
+}
+
+// SetUploadRate sets the upload rate limit (bytes/s) of the torrent, 0 means unlimited, see SetDownloadRate
+func (r *RTorrent) SetUploadRate(t Torrent, bytesPerSec int) error {
+	return r.setTorrentRate(t, "throttle.up", bytesPerSec)
+}
+
+// UploadRate returns the upload rate limit (bytes/s) of the throttle group of the torrent, 0 means unlimited
+func (r *RTorrent) UploadRate(t Torrent) (int, error) {
+	return r.torrentRate(t, "throttle.up.max")
+}
+
+// torrentThrottleGroup returns the name of the throttle group used for the limits of a single torrent
+func torrentThrottleGroup(t Torrent) string {
+	return "torrent_" + strings.ToLower(t.Hash)
+}
+
+// setTorrentRate sets the limit of the throttle group of the torrent, and assigns the torrent to it
+func (r *RTorrent) setTorrentRate(t Torrent, cmd string, bytesPerSec int) error {
+	group := torrentThrottleGroup(t)
+	if err := r.setThrottle(cmd, group, bytesPerSec); err != nil {
+		return err
+	}
+	return r.SetThrottleGroup(t, group)
+}
+
+// torrentRate returns the limit of the throttle group of the torrent, a torrent without group is unlimited
+func (r *RTorrent) torrentRate(t Torrent, cmd string) (int, error) {
+	group, err := r.ThrottleGroup(t)
+	if err != nil || group == "" {
+		return 0, err
+	}
+	return r.throttleMax(cmd, group)
+}
+
+// setThrottle creates or updates the throttle group, rTorrent expects the rate as a string in KiB/s
+func (r *RTorrent) setThrottle(cmd, group string, bytesPerSec int) error {
+	if bytesPerSec < 0 {
+		return errors.Errorf("invalid rate limit: %d", bytesPerSec)
+	}
+	kib := (bytesPerSec + 1023) / 1024
+	return r.callDiscard(cmd, "", group, strconv.Itoa(kib))
+}
+
+// throttleMax returns the limit of the throttle group (bytes/s), a group which was never set is unlimited
+func (r *RTorrent) throttleMax(cmd, group string) (int, error) {
+	limit, err := r.callInt(cmd, "", group)
+	if err != nil || limit < 0 {
+		return 0, err
+	}
+	return limit, nil
+}
+
+// torrentField maps a column of a d.multicall2 call onto a Torrent
+// Columns without a set function are only used to derive Torrent.State, see torrentFromRow.
+type torrentField struct {
+	field Field
+	set   func(t *Torrent, value interface{})
+}
+
+// torrentFields are the columns requested by GetTorrents
+// Results are mapped back by their index in this list, so fields can be added, removed or reordered freely.
+var torrentFields = []torrentField{
+	{DHash, func(t *Torrent, v interface{}) { t.Hash = asString(v) }},
+	{DName, func(t *Torrent, v interface{}) { t.Name = asString(v) }},
+	{DDirectory, func(t *Torrent, v interface{}) { t.Path = asString(v) }},
+	{DSizeInBytes, func(t *Torrent, v interface{}) { t.Size = asInt64(v) }},
+	{DLabel, func(t *Torrent, v interface{}) { t.Label = asString(v) }},
+	{DComplete, func(t *Torrent, v interface{}) { t.Completed = asInt(v) > 0 }},
+	{DCompletedBytes, func(t *Torrent, v interface{}) { t.CompletedBytes = asInt64(v) }},
+	{DRatio, func(t *Torrent, v interface{}) { t.Ratio = float64(asInt(v)) / float64(1000) }},
+	{DCreationTime, func(t *Torrent, v interface{}) { t.Created = time.Unix(asInt64(v), 0) }},
+	{DFinishedTime, func(t *Torrent, v interface{}) {
+		t.Finished = time.Unix(asInt64(v), 0)
+		t.FinishedAt = asTime(v)
+	}},
+	{DStartedTime, func(t *Torrent, v interface{}) { t.Started = time.Unix(asInt64(v), 0) }},
+	{DUpTotal, func(t *Torrent, v interface{}) { t.Uploaded = asInt64(v) }},
+	{DDownTotal, func(t *Torrent, v interface{}) { t.Downloaded = asInt64(v) }},
+	{DPriority, func(t *Torrent, v interface{}) { t.Priority = Priority(asInt(v)) }},
+	{DMessage, func(t *Torrent, v interface{}) { t.Message = asString(v) }},
+	{DLoadDate, func(t *Torrent, v interface{}) { t.AddedAt = asTime(v) }},
+	{DState, nil},
+	{DIsActive, nil},
+	{DHashing, nil},
+}
+
+// GetTorrents returns all of the torrents reported by this RTorrent instance
+func (r *RTorrent) GetTorrents(view View) ([]Torrent, error) {
+	return r.GetTorrentsContext(context.Background(), view)
+}
+
+// GetTorrentsContext is like GetTorrents, the request is aborted when ctx is done
+func (r *RTorrent) GetTorrentsContext(ctx context.Context, view View) ([]Torrent, error) {
+	return r.getTorrents(ctx, view, torrentFields)
+}
+
+// GetTorrentsFiltered returns the torrents of the view matching filter, which rTorrent evaluates for each torrent
+// The filter is a command expression like in rtorrent.rc, for instance:
+//  GetTorrentsFiltered(ViewMain, "d.complete=")
+//  GetTorrentsFiltered(ViewMain, "equal={d.custom1=,cat=movies}")
+// Torrents are filtered server-side with d.multicall.filtered (rTorrent 0.9.7 or later), only the matching ones are
+// transferred, which is much faster than GetTorrentsWhere on large instances.
+func (r *RTorrent) GetTorrentsFiltered(view View, filter string) ([]Torrent, error) {
+	return r.listTorrents(context.Background(), "d.multicall.filtered", []interface{}{"", string(view), filter},
+		torrentFields)
+}
+
+// GetTorrentsWhere returns the torrents of the view for which pred returns true
+// All the torrents of the view are retrieved and filtered client-side, see GetTorrentsFiltered to let rTorrent filter.
+func (r *RTorrent) GetTorrentsWhere(view View, pred func(Torrent) bool) ([]Torrent, error) {
+	torrents, err := r.GetTorrents(view)
+	if err != nil {
+		return nil, err
+	}
+	var matching []Torrent
+	for _, t := range torrents {
+		if pred(t) {
+			matching = append(matching, t)
+		}
+	}
+	return matching, nil
+}
+
+// GetTorrentsWithFields returns the torrents of the view, only reading the given fields, e.g. for a "top talkers" list:
+//  GetTorrentsWithFields(ViewMain, DName, DUpTotal, DDownTotal)
+// The hash is always read, the other fields of the returned torrents are left at their zero value. Only the fields
+// GetTorrents reads are supported; DState also reads d.is_active, d.hashing and d.complete to derive Torrent.State.
+func (r *RTorrent) GetTorrentsWithFields(view View, fields ...Field) ([]Torrent, error) {
+	supported := make(map[Field]bool, len(torrentFields))
+	for _, f := range torrentFields {
+		supported[f.field] = true
+	}
+	wanted := map[Field]bool{DHash: true}
+	for _, field := range fields {
+		if !supported[field] {
+			return nil, errors.Errorf("unsupported torrent field: %s", field)
+		}
+		wanted[field] = true
+	}
+	if wanted[DState] {
+		wanted[DIsActive], wanted[DHashing], wanted[DComplete] = true, true, true
+	}
+	selected := make([]torrentField, 0, len(wanted))
+	for _, f := range torrentFields {
+		if wanted[f.field] {
+			selected = append(selected, f)
+		}
+	}
+	return r.getTorrents(context.Background(), view, selected)
+}
+
+func (r *RTorrent) getTorrents(ctx context.Context, view View, fields []torrentField) ([]Torrent, error) {
+	return r.listTorrents(ctx, "d.multicall2", []interface{}{"", string(view)}, fields)
+}
+
+// listTorrents calls the d.multicall2 like method with args followed by the queries of the fields
+func (r *RTorrent) listTorrents(ctx context.Context, method string, args []interface{}, fields []torrentField) ([]Torrent, error) {
+	for _, f := range fields {
+		args = append(args, f.field.Query())
+	}
+	results, err := r.callContext(ctx, method, args...)
+	var torrents []Torrent
+	if err != nil {
+		return torrents, errors.Wrapf(err, "%s XMLRPC call failed", method)
+	}
+	for _, outerResult := range asList(results) {
+		for _, innerResult := range asList(outerResult) {
+			torrents = append(torrents, torrentFromRow(fields, asList(innerResult)))
+		}
+	}
+	return torrents, nil
+}
+
+// torrentFromRow maps a row of a d.multicall2 result onto a Torrent
+// Columns missing from the row are left at their zero value.
+func torrentFromRow(fields []torrentField, row []interface{}) Torrent {
+	var t Torrent
+	values := make(map[Field]interface{}, len(fields))
+	for i := 0; i < len(fields) && i < len(row); i++ {
+		values[fields[i].field] = row[i]
+		if fields[i].set != nil {
+			fields[i].set(&t, row[i])
+		}
+	}
+	if _, ok := values[DState]; ok {
+		t.State = torrentState(values)
+	}
+	return t
+}
+
+// torrentState derives the state of a torrent from the values of its fields, see TorrentState
+func torrentState(values map[Field]interface{}) TorrentState {
+	switch {
+	case asInt(values[DHashing]) != 0:
+		return StateHashing
+	case asInt(values[DState]) == 0:
+		return StateStopped
+	case asInt(values[DIsActive]) == 1 && asInt(values[DComplete]) == 1:
+		return StateSeeding
+	}
+	return StateStarted
+}
+
+// GetHashingTorrents returns the torrents which are currently being hash checked, along with their progress
+// The torrents and their progress are read in a single system.multicall request, the progress is computed from
+// d.chunks_hashed and d.size_chunks.
+func (r *RTorrent) GetHashingTorrents() ([]HashingTorrent, error) {
+	args := []interface{}{"", string(ViewHashing)}
+	for _, f := range torrentFields {
+		args = append(args, f.field.Query())
+	}
+	results, err := r.multicall(
+		call{"d.multicall2", args},
+		call{"d.multicall2", []interface{}{"", string(ViewHashing), DHash.Query(), DChunksHashed.Query(), DSizeChunks.Query()}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	progress := make(map[string]float64)
+	for _, row := range asList(results[1]) {
+		row := asList(row)
+		if len(row) < 3 {
+			continue
+		}
+		if size := asInt(row[2]); size > 0 {
+			progress[asString(row[0])] = float64(asInt(row[1])) / float64(size)
+		}
+	}
+	var torrents []HashingTorrent
+	for _, row := range asList(results[0]) {
+		t := torrentFromRow(torrentFields, asList(row))
+		torrents = append(torrents, HashingTorrent{Torrent: t, HashingProgress: progress[t.Hash]})
+	}
+	return torrents, nil
+}
+
+// GetTorrentsLite returns the hash, name and size of all of the torrents reported by this RTorrent instance
+// Only those three columns are requested, so both the response and the resulting slice are a fraction of the
+// size of what GetTorrents produces. Prefer it over GetTorrents when listing very large instances.
+func (r *RTorrent) GetTorrentsLite(view View) ([]TorrentLite, error) {
+	args := []interface{}{"", string(view), DHash.Query(), DName.Query(), DSizeInBytes.Query()}
+	result, err := r.callValue("d.multicall2", args...)
+	if err != nil {
+		return nil, err
+	}
+	rows := asList(result)
+	torrents := make([]TorrentLite, 0, len(rows))
+	for _, row := range rows {
+		torrentData := asList(row)
+		if len(torrentData) < 3 {
+			continue
+		}
+		torrents = append(torrents, TorrentLite{
+			Hash: asString(torrentData[0]),
+			Name: asString(torrentData[1]),
+			Size: asInt64(torrentData[2]),
+		})
+	}
+	return torrents, nil
+}
+
+// GetViews returns the names of all of the views of this RTorrent instance, including user created ones
+func (r *RTorrent) GetViews() ([]string, error) {
+	result, err := r.callValue("view.list")
+	if err != nil {
+		return nil, err
+	}
+	var views []string
+	for _, view := range asList(result) {
+		views = append(views, asString(view))
+	}
+	return views, nil
+}
+
+// GetLabels returns the distinct labels of the torrents of the main view, sorted and without the empty label
+// Labels are compared as is, so labels differing in case are all returned.
+func (r *RTorrent) GetLabels() ([]string, error) {
+	rows, err := r.callList("d.multicall2", "", string(ViewMain), DLabel.Query())
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	labels := []string{}
+	for _, result := range rows {
+		row := asList(result)
+		if len(row) < 1 {
+			continue
+		}
+		label := asString(row[0])
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// CreateView creates a view with the given name, unless it exists already
+func (r *RTorrent) CreateView(name string) error {
+	views, err := r.GetViews()
+	if err != nil {
+		return err
+	}
+	for _, view := range views {
+		if view == name {
+			return nil
+		}
+	}
+	return r.callDiscard("view.add", "", name)
+}
+
+// AddToView adds the torrent to the view
+// The view is recorded in the views of the torrent (d.views) and the torrent is made visible in the view right away.
+func (r *RTorrent) AddToView(t Torrent, view View) error {
+	_, err := r.multicall(
+		call{"d.views.push_back_unique", []interface{}{t.Hash, string(view)}},
+		call{"view.set_visible", []interface{}{t.Hash, string(view)}},
+	)
+	return err
+}
+
+// LabelView returns the name of the view used for the label by AssignLabel: "label_" followed by the label
+func LabelView(label string) View {
+	return View("label_" + label)
+}
+
+// AssignLabel sets the label on the given Torrent and adds it to the view of the label, see LabelView
+// The view is created if it does not exist yet. Views created this way only last until rTorrent restarts.
+func (r *RTorrent) AssignLabel(t Torrent, label string) error {
+	if err := r.SetLabel(t, label); err != nil {
+		return err
+	}
+	view := LabelView(label)
+	if err := r.CreateView(string(view)); err != nil {
+		return err
+	}
+	return r.AddToView(t, view)
+}
+
+// GetAllTorrentsWithViews returns every torrent once, along with the names of all of the views it belongs to
+// It takes three requests: view.list, GetTorrents on the main view and a single system.multicall listing the
+// hashes of every view.
+func (r *RTorrent) GetAllTorrentsWithViews() ([]TorrentWithViews, error) {
+	views, err := r.GetViews()
+	if err != nil {
+		return nil, err
+	}
+	torrents, err := r.GetTorrents(ViewMain)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]call, 0, len(views))
+	for _, view := range views {
+		calls = append(calls, call{"d.multicall2", []interface{}{"", view, DHash.Query()}})
+	}
+	results, err := r.multicall(calls...)
+	if err != nil {
+		return nil, err
+	}
+	membership := make(map[string][]string)
+	for i, result := range results {
+		for _, torrentData := range asList(result) {
+			row := asList(torrentData)
+			if len(row) < 1 {
+				continue
+			}
+			hash := asString(row[0])
+			membership[hash] = append(membership[hash], views[i])
+		}
+	}
+	seen := make(map[string]bool, len(torrents))
+	withViews := make([]TorrentWithViews, 0, len(torrents))
+	for _, t := range torrents {
+		if seen[t.Hash] {
+			continue
+		}
+		seen[t.Hash] = true
+		withViews = append(withViews, TorrentWithViews{Torrent: t, Views: membership[t.Hash]})
+	}
+	return withViews, nil
+}
+
+// GetTorrent returns the torrent identified by the given hash
+// The torrent is read with the same commands as GetTorrents, in a single system.multicall request.
+func (r *RTorrent) GetTorrent(hash string) (Torrent, error) {
+	return r.GetTorrentContext(context.Background(), hash)
+}
+
+// GetTorrentContext is like GetTorrent, the request is aborted when ctx is done
+func (r *RTorrent) GetTorrentContext(ctx context.Context, hash string) (Torrent, error) {
+	calls := make([]call, 0, len(torrentFields))
+	for _, f := range torrentFields {
+		calls = append(calls, call{f.field.Cmd(), []interface{}{hash}})
+	}
+	results, err := r.multicallContext(ctx, calls...)
+	if err != nil {
+		return Torrent{Hash: hash}, err
+	}
+	return torrentFromRow(torrentFields, results), nil
+}
+
+// Delete removes the torrent
+func (r *RTorrent) Delete(t Torrent) error {
+	return r.DeleteContext(context.Background(), t)
+}
+
+// DeleteContext is like Delete, the request is aborted when ctx is done
+func (r *RTorrent) DeleteContext(ctx context.Context, t Torrent) error {
+	_, err := r.callContext(ctx, "d.erase", t.Hash)
+	if err != nil {
+		return errors.Wrap(err, "d.erase XMLRPC call failed")
+	}
+	return nil
+}
+
+// DeleteWithData removes the torrent and deletes its data on the rTorrent host
+// rTorrent can't delete files itself: the files of the torrent are listed, the torrent is erased, then each of its
+// files is removed with rm -f via execute.throw, so rm must be available to rTorrent. The directories of multi file
+// torrents are removed with rmdir once empty, except the default download directory (directory.default) and its
+// parents; directories holding anything else are kept. Only the files of the torrent are ever deleted, even when it
+// shares its directory with other downloads.
+// Deleting goes on past the files which fail, they are returned in a single error. The torrent stays removed
+// when deleting the data fails, the error then says so.
+// With WithEraseDataHook the torrent is marked for deletion and erased instead, leaving the data to ruTorrent.
+func (r *RTorrent) DeleteWithData(t Torrent) error {
+	if r.eraseDataHook {
+		_, err := r.multicall(
+			call{DCustom5.Cmd() + ".set", []interface{}{t.Hash, eraseDataMark}},
+			call{"d.erase", []interface{}{t.Hash}},
+		)
+		return err
+	}
+	defaultDir, err := r.callString("directory.default")
+	if err != nil {
+		return err
+	}
+	data, err := r.torrentData(t.Hash, defaultDir)
+	if err != nil {
+		return err
+	}
+	if err := r.Delete(t); err != nil {
+		return err
+	}
+	return errors.Wrap(r.removeData(data), "torrent removed but deleting its data failed")
+}
+
+// eraseDataMark is the value of d.custom5 telling the erasedata plugin of ruTorrent to delete the data of a torrent
+const eraseDataMark = "1"
+
+// dataFiles are the files of a torrent to delete and the directories which may be left empty, see DeleteWithData
+type dataFiles struct {
+	files []string
+	dirs  []string
+}
+
+// torrentData lists the files of the torrent, and for multi file torrents the directories containing them up to
+// d.directory, leaving out defaultDir and its parents
+// The files are read from f.frozen_path, or d.directory joined with f.path when the torrent was never opened.
+func (r *RTorrent) torrentData(hash, defaultDir string) (dataFiles, error) {
+	results, err := r.multicall(
+		call{DDirectory.Cmd(), []interface{}{hash}},
+		call{DIsMultiFile.Cmd(), []interface{}{hash}},
+		call{"f.multicall", []interface{}{hash, 0, FFrozenPath.Query(), FPath.Query()}},
+	)
+	if err != nil {
+		return dataFiles{}, err
+	}
+	directory := path.Clean(asString(results[0]))
+	if err := checkDeletable(directory); err != nil {
+		return dataFiles{}, err
+	}
+	kept := func(dir string) bool {
+		return dir == directory || strings.HasPrefix(dir, directory+"/")
+	}
+	var data dataFiles
+	dirs := make(map[string]bool)
+	for _, row := range asList(results[2]) {
+		columns := asList(row)
+		if len(columns) < 2 {
+			continue
+		}
+		file := asString(columns[0])
+		if file == "" {
+			file = path.Join(directory, asString(columns[1]))
+		}
+		file = path.Clean(file)
+		if err := checkDeletable(file); err != nil {
+			return dataFiles{}, err
+		}
+		if !kept(path.Dir(file)) {
+			return dataFiles{}, errors.Errorf("refusing to delete %q outside of %q", file, directory)
+		}
+		data.files = append(data.files, file)
+		if asInt(results[1]) != 1 {
+			continue
+		}
+		for dir := path.Dir(file); kept(dir) && !dirs[dir]; dir = path.Dir(dir) {
+			if !isParentOrSame(dir, defaultDir) {
+				dirs[dir] = true
+				data.dirs = append(data.dirs, dir)
+			}
+			if dir == directory {
+				break
+			}
+		}
+	}
+	return data, nil
+}
+
+// isParentOrSame returns whether dir is the directory other or one of its parents
+func isParentOrSame(dir, other string) bool {
+	if other == "" {
+		return false
+	}
+	other = path.Clean(other)
+	return dir == other || dir == "/" || strings.HasPrefix(other, dir+"/")
+}
+
+// removeData deletes the files with rm -f, then the directories deepest first with rmdir, in a single system.multicall
+// The files which fail to be deleted are returned in a single error, the directories which can't be removed are kept
+// silently: they usually hold other files.
+func (r *RTorrent) removeData(data ...dataFiles) error {
+	var files, dirs []string
+	for _, d := range data {
+		files = append(files, d.files...)
+		dirs = append(dirs, d.dirs...)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	calls := make([]xmlrpc.Call, 0, len(files)+len(dirs))
+	for _, file := range files {
+		calls = append(calls, xmlrpc.Call{Name: "execute.throw", Args: []interface{}{"", "rm", "-f", "--", file}})
+	}
+	for _, dir := range dirs {
+		calls = append(calls, xmlrpc.Call{Name: "execute.throw", Args: []interface{}{"", "rmdir", "--", dir}})
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+	values, err := xmlrpc.MultiCallWith(context.Background(), r.callContext, calls)
+	if err != nil {
+		return errors.Wrap(err, "system.multicall XMLRPC call failed")
+	}
+	var failed []string
+	for i, value := range values[:len(files)] {
+		if fault, ok := value.(xmlrpc.Fault); ok {
+			failed = append(failed, fmt.Sprintf("%s: %v", files[i], fault))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to delete %d of %d files: %s", len(failed), len(files), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// checkDeletable refuses to delete anything but an absolute path which isn't the root directory
+func checkDeletable(data string) error {
+	if !path.IsAbs(data) || path.Dir(data) == data {
+		return errors.Errorf("refusing to delete %q", data)
+	}
+	return nil
+}
+
+// CleanupCompleted removes the complete torrents of the view which reached minRatio, and returns their hashes
+// minRatio must be greater than 0, so that a zero value can't remove every complete torrent. Their data is only
+// deleted when withData is true, see DeleteWithData and WithEraseDataHook.
+// The torrents are read with a single d.multicall2 and removed with a single system.multicall. If any of the
+// removals fails an error is returned, the torrents before it in the batch are removed nonetheless. With data, the
+// files of each torrent are listed before anything is removed, then deleted like DeleteWithData does once all the
+// torrents were removed; the hashes are returned along with the error when deleting some of the files failed.
+func (r *RTorrent) CleanupCompleted(view View, minRatio float64, withData bool) ([]string, error) {
+	if minRatio <= 0 {
+		return nil, errors.Errorf("invalid minimum ratio: %v", minRatio)
+	}
+	args := []interface{}{"", string(view), DHash.Query(), DComplete.Query(), DRatio.Query()}
+	rows, err := r.callList("d.multicall2", args...)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	var erase []call
+	for _, result := range rows {
+		torrentData := asList(result)
+		if len(torrentData) < 3 {
+			continue
+		}
+		ratio := float64(asInt(torrentData[2])) / float64(1000)
+		if asInt(torrentData[1]) != 1 || ratio < minRatio {
+			continue
+		}
+		hash := asString(torrentData[0])
+		if withData && r.eraseDataHook {
+			erase = append(erase, call{DCustom5.Cmd() + ".set", []interface{}{hash, eraseDataMark}})
+		}
+		hashes = append(hashes, hash)
+		erase = append(erase, call{"d.erase", []interface{}{hash}})
+	}
+	if len(erase) == 0 {
+		return nil, nil
+	}
+	var data []dataFiles
+	if withData && !r.eraseDataHook {
+		defaultDir, err := r.callString("directory.default")
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			d, err := r.torrentData(hash, defaultDir)
+			if err != nil {
+				return nil, errors.Wrapf(err, "torrent %s", hash)
+			}
+			data = append(data, d)
+		}
+	}
+	if _, err := r.multicall(erase...); err != nil {
+		return nil, err
+	}
+	if err := r.removeData(data...); err != nil {
+		return hashes, errors.Wrap(err, "torrents removed but deleting their data failed")
+	}
+	return hashes, nil
+}
+
+// GetFiles returns all of the files for a given `Torrent`
+func (r *RTorrent) GetFiles(t Torrent) ([]File, error) {
+	return r.GetFilesContext(context.Background(), t)
+}
+
+// GetFilesContext is like GetFiles, the request is aborted when ctx is done
+func (r *RTorrent) GetFilesContext(ctx context.Context, t Torrent) ([]File, error) {
+	args := []interface{}{t.Hash, 0, FPath.Query(), FSizeInBytes.Query(), FPriority.Query(), FCompletedChunks.Query(),
+		FSizeChunks.Query()}
+	results, err := r.callContext(ctx, "f.multicall", args...)
+	var files []File
+	if err != nil {
+		if r.isMeta(t) {
+			return []File{}, nil
+		}
+		return files, errors.Wrap(err, "f.multicall XMLRPC call failed")
+	}
+	for _, outerResult := range asList(results) {
+		for _, innerResult := range asList(outerResult) {
+			fileData := asList(innerResult)
+			if len(fileData) < 5 {
+				continue
+			}
+			files = append(files, File{
+				Path:            asString(fileData[0]),
+				Size:            asInt64(fileData[1]),
+				Priority:        asInt(fileData[2]),
+				CompletedChunks: asInt(fileData[3]),
+				SizeChunks:      asInt(fileData[4]),
+			})
+		}
+	}
+	// the only file of a magnet link without metadata is a placeholder named after its hash
+	if len(files) == 1 && strings.HasSuffix(files[0].Path, ".meta") && r.isMeta(t) {
+		return []File{}, nil
+	}
+	return files, nil
+}
+
+// isMeta checks if the torrent is a magnet link whose metadata is not retrieved yet, false if it can't be told
+func (r *RTorrent) isMeta(t Torrent) bool {
+	meta, err := r.callBool(DIsMeta.Cmd(), t.Hash)
+	return err == nil && meta
+}
+
+// GetTrackers returns all of the trackers for a given `Torrent`
+func (r *RTorrent) GetTrackers(t Torrent) ([]Tracker, error) {
+	args := []interface{}{t.Hash, "", TURL.Query(), TType.Query(), TIsEnabled.Query(), TScrapeComplete.Query(),
+		TScrapeIncomplete.Query(), TMinInterval.Query(), TActivityTimeNext.Query()}
+	rows, err := r.callList("t.multicall", args...)
+	var trackers []Tracker
+	if err != nil {
+		return trackers, err
+	}
+	for _, result := range rows {
+		trackerData := asList(result)
+		if len(trackerData) < 7 {
+			continue
+		}
+		tracker := Tracker{
+			URL:              asString(trackerData[0]),
+			Type:             TrackerType(asInt(trackerData[1])),
+			Enabled:          asInt(trackerData[2]) == 1,
+			ScrapeComplete:   asInt(trackerData[3]),
+			ScrapeIncomplete: asInt(trackerData[4]),
+			MinInterval:      time.Duration(asInt(trackerData[5])) * time.Second,
+		}
+		if next := asInt(trackerData[6]); next > 0 {
+			tracker.NextAnnounce = time.Unix(int64(next), 0)
+		}
+		trackers = append(trackers, tracker)
+	}
+	return trackers, nil
+}
+
+// GetPeers returns the peers connected to a given `Torrent`, an empty slice when there are none
+func (r *RTorrent) GetPeers(t Torrent) ([]Peer, error) {
+	args := []interface{}{t.Hash, "", PAddress.Query(), PPort.Query(), PClientVersion.Query(), PDownRate.Query(),
+		PUpRate.Query(), PCompletedPercent.Query(), PIsEncrypted.Query()}
+	rows, err := r.callList("p.multicall", args...)
+	peers := []Peer{}
+	if err != nil {
+		return peers, err
+	}
+	for _, result := range rows {
+		peerData := asList(result)
+		if len(peerData) < 7 {
+			continue
+		}
+		peers = append(peers, Peer{
+			Address:          asString(peerData[0]),
+			Port:             asInt(peerData[1]),
+			ClientVersion:    asString(peerData[2]),
+			DownRate:         asInt(peerData[3]),
+			UpRate:           asInt(peerData[4]),
+			CompletedPercent: asInt(peerData[5]),
+			Encrypted:        asInt(peerData[6]) == 1,
+		})
+	}
+	return peers, nil
+}
+
+// IsPartiallySelected checks if some of the files of the torrent have been deselected
+// A torrent is considered partially selected when at least one of its files has a priority of 0 (off),
+// as reported by f.priority. Such files are skipped by rTorrent, so the wanted size of the torrent will
+// be smaller than the size reported by d.size_bytes. The sizes aren't compared as well: the wanted size, see
+// WantedSize, only differs from d.size_bytes when a file is off, so the priorities alone tell it.
+func (r *RTorrent) IsPartiallySelected(t Torrent) (bool, error) {
+	args := []interface{}{t.Hash, 0, FPriority.Query()}
+	rows, err := r.callList("f.multicall", args...)
+	if err != nil {
+		return false, err
+	}
+	for _, result := range rows {
+		fileData := asList(result)
+		if len(fileData) < 1 {
+			continue
+		}
+		if asInt(fileData[0]) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetFilePriority sets the download priority of the file at fileIndex (its position in GetFiles) of the torrent
+// The priority is one of FilePriorityOff, FilePriorityNormal or FilePriorityHigh. rTorrent only applies the new
+// priorities once UpdatePriorities is called, which allows changing several files first.
+func (r *RTorrent) SetFilePriority(t Torrent, fileIndex int, priority int) error {
+	if fileIndex < 0 {
+		return errors.Errorf("invalid file index: %d", fileIndex)
+	}
+	if priority < FilePriorityOff || priority > FilePriorityHigh {
+		return errors.Errorf("invalid file priority: %d", priority)
+	}
+	target := fmt.Sprintf("%s:f%d", t.Hash, fileIndex)
+	return r.callDiscard("f.priority.set", target, priority)
+}
+
+// UpdatePriorities applies the file priorities set with SetFilePriority (d.update_priorities)
+func (r *RTorrent) UpdatePriorities(t Torrent) error {
+	return r.callDiscard("d.update_priorities", t.Hash)
+}
+
+// WantedSize returns the size of the data of the torrent which will be downloaded (bytes)
+// It is the sum of the sizes of the files which are not skipped (f.priority greater than 0), computed on every
+// call. It is smaller than the size of the torrent when it is partially selected, see IsPartiallySelected.
+func (r *RTorrent) WantedSize(t Torrent) (int64, error) {
+	args := []interface{}{t.Hash, 0, FSizeInBytes.Query(), FPriority.Query()}
+	rows, err := r.callList("f.multicall", args...)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, result := range rows {
+		fileData := asList(result)
+		if len(fileData) < 2 {
+			continue
+		}
+		if asInt(fileData[1]) > 0 {
+			size += asInt64(fileData[0])
+		}
+	}
+	return size, nil
+}
+
+// SetLabel sets the label on the given Torrent
+func (r *RTorrent) SetLabel(t Torrent, newLabel string) error {
+	t.Label = newLabel
+	args := []interface{}{t.Hash, newLabel}
+	return r.callDiscard("d.custom1.set", args...)
+}
+
+// SetLabelAll sets the label on all the given torrents with a single system.multicall
+// A failure of some of the torrents doesn't stop the others, they are returned as a *BatchError.
+func (r *RTorrent) SetLabelAll(ts []Torrent, newLabel string) error {
+	return r.batch(ts, "d.custom1.set", newLabel)
+}
+
+// DefaultTagDelimiter is the delimiter between the tags of a label, see WithTagDelimiter
+const DefaultTagDelimiter = ","
+
+// GetTags returns the tags of the given Torrent: its label split on the tag delimiter, see WithTagDelimiter
+// The label is URL-decoded first, as ruTorrent percent-encodes it, and empty tags are skipped.
+func (r *RTorrent) GetTags(t Torrent) ([]string, error) {
+	label, err := r.callString("d.custom1", t.Hash)
+	if err != nil {
+		return nil, err
+	}
+	// labels which weren't set by ruTorrent may contain a literal '%'
+	if decoded, err := url.PathUnescape(label); err == nil {
+		label = decoded
+	}
+	tags := []string{}
+	for _, tag := range strings.Split(label, r.tagDelimiter) {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// SetTags sets the label of the given Torrent to the tags, each one URL-encoded, joined by the tag delimiter
+// A tag containing the delimiter can't be told apart from two tags and is returned as an error.
+func (r *RTorrent) SetTags(t Torrent, tags []string) error {
+	encoded := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if r.tagDelimiter != "" && strings.Contains(tag, r.tagDelimiter) {
+			return errors.Errorf("tag %q contains the delimiter %q", tag, r.tagDelimiter)
+		}
+		if tag != "" {
+			encoded = append(encoded, url.PathEscape(tag))
+		}
+	}
+	return r.SetLabel(t, strings.Join(encoded, r.tagDelimiter))
+}
+
+// customFields are the numbered custom fields of a torrent, custom1 being the label
+var customFields = []Field{DLabel, DCustom2, DCustom3, DCustom4, DCustom5}
+
+// customField returns the custom field numbered n, from 1 to 5
+func customField(n int) (Field, error) {
+	if n < 1 || n > len(customFields) {
+		return "", errors.Errorf("invalid custom field: %d, must be between 1 and %d", n, len(customFields))
+	}
+	return customFields[n-1], nil
+}
+
+// GetCustom returns the value of the custom field numbered n (d.custom1 to d.custom5) of the given Torrent
+// d.custom1 is used as the label, see SetLabel.
+func (r *RTorrent) GetCustom(t Torrent, n int) (string, error) {
+	field, err := customField(n)
+	if err != nil {
+		return "", err
+	}
+	return r.callString(field.Cmd(), t.Hash)
+}
+
+// SetCustom sets the value of the custom field numbered n (d.custom1 to d.custom5) on the given Torrent
+func (r *RTorrent) SetCustom(t Torrent, n int, value string) error {
+	field, err := customField(n)
+	if err != nil {
+		return err
+	}
+	return r.callDiscard(field.Cmd()+".set", t.Hash, value)
+}
+
+// GetCustomKey returns the value stored under the key on the given Torrent (d.custom)
+// An empty string is returned for a key which was never set.
+func (r *RTorrent) GetCustomKey(t Torrent, key string) (string, error) {
+	if key == "" {
+		return "", errors.New("custom key must not be empty")
+	}
+	return r.callString("d.custom", t.Hash, key)
+}
+
+// SetCustomKey stores the value under the key on the given Torrent (d.custom.set)
+// Unlike the numbered custom fields, any number of keys can be used. The values are kept in the session of the torrent.
+func (r *RTorrent) SetCustomKey(t Torrent, key, value string) error {
+	if key == "" {
+		return errors.New("custom key must not be empty")
+	}
+	return r.callDiscard("d.custom.set", t.Hash, key, value)
+}
+
+// GetPriority returns the priority of the given Torrent
+func (r *RTorrent) GetPriority(t Torrent) (Priority, error) {
+	priority, err := r.callInt(DPriority.Cmd(), t.Hash)
+	return Priority(priority), err
+}
+
+// SetPriority sets the priority of the given Torrent
+func (r *RTorrent) SetPriority(t Torrent, priority Priority) error {
+	if priority < PriorityOff || priority > PriorityHigh {
+		return errors.Errorf("invalid priority: %d", priority)
+	}
+	return r.callDiscard("d.priority.set", t.Hash, int(priority))
+}
+
+// statusField maps the result of a call for a torrent onto a Status
+type statusField struct {
+	field Field
+	set   func(s *Status, value interface{})
+}
+
+// statusFields are the calls batched by GetStatus, results are mapped back by their index in this list
+var statusFields = []statusField{
+	{DComplete, func(s *Status, v interface{}) { s.Completed = asInt(v) > 0 }},
+	{DCompletedBytes, func(s *Status, v interface{}) { s.CompletedBytes = asInt64(v) }},
+	{DDownRate, func(s *Status, v interface{}) { s.DownRate = asInt(v) }},
+	{DUpRate, func(s *Status, v interface{}) { s.UpRate = asInt(v) }},
+	{DRatio, func(s *Status, v interface{}) { s.Ratio = float64(asInt(v)) / float64(1000) }},
+	{DSizeInBytes, func(s *Status, v interface{}) { s.Size = asInt64(v) }},
+	{DCompletedChunks, func(s *Status, v interface{}) { s.CompletedChunks = asInt64(v) }},
+	{DSizeChunks, func(s *Status, v interface{}) { s.SizeChunks = asInt64(v) }},
+	{DChunkSize, func(s *Status, v interface{}) { s.ChunkSize = asInt64(v) }},
+	{DPeersConnected, func(s *Status, v interface{}) { s.PeersConnected = asInt(v) }},
+	{DPeersComplete, func(s *Status, v interface{}) { s.SeedersConnected = asInt(v) }},
+	{DPeersAccounted, func(s *Status, v interface{}) { s.LeechersConnected = asInt(v) }},
+}
+
+// GetStatus returns the Status for a given Torrent
+// All of the values are read in a single system.multicall request.
 func (r *RTorrent) GetStatus(t Torrent) (Status, error) {
+	return r.GetStatusContext(context.Background(), t)
+}
+
+// GetStatusContext is like GetStatus, the request is aborted when ctx is done
+func (r *RTorrent) GetStatusContext(ctx context.Context, t Torrent) (Status, error) {
 	var s Status
-	// Completed
-	results, err := r.xmlrpcClient.Call("d.complete", t.Hash)
-	if err != nil {
-		return s, errors.Wrap(err, "d.complete XMLRPC call failed")
+	calls := make([]call, 0, len(statusFields))
+	for _, f := range statusFields {
+		calls = append(calls, call{f.field.Cmd(), []interface{}{t.Hash}})
 	}
-	s.Completed = results.([]interface{})[0].(int) > 0
-	// CompletedBytes
-	results, err = r.xmlrpcClient.Call("d.completed_bytes", t.Hash)
+	results, err := r.multicallContext(ctx, calls...)
 	if err != nil {
-		return s, errors.Wrap(err, "d.completed_bytes XMLRPC call failed")
+		return s, err
 	}
-	s.CompletedBytes = results.([]interface{})[0].(int)
-	// DownRate
-	results, err = r.xmlrpcClient.Call("d.down.rate", t.Hash)
-	if err != nil {
-		return s, errors.Wrap(err, "d.down.rate XMLRPC call failed")
+	for i, f := range statusFields {
+		f.set(&s, results[i])
 	}
-	s.DownRate = results.([]interface{})[0].(int)
-	// UpRate
-	results, err = r.xmlrpcClient.Call("d.up.rate", t.Hash)
-	if err != nil {
-		return s, errors.Wrap(err, "d.up.rate XMLRPC call failed")
+	return s, nil
+}
+
+// GetTorrentSnapshot returns the torrent identified by the given hash along with its status and state
+// Everything is read in a single system.multicall request, see TorrentSnapshot for the commands used.
+func (r *RTorrent) GetTorrentSnapshot(hash string) (TorrentSnapshot, error) {
+	var calls []call
+	for _, f := range torrentFields {
+		calls = append(calls, call{f.field.Cmd(), []interface{}{hash}})
 	}
-	s.UpRate = results.([]interface{})[0].(int)
-	// Ratio
-	results, err = r.xmlrpcClient.Call("d.ratio", t.Hash)
-	if err != nil {
-		return s, errors.Wrap(err, "d.ratio XMLRPC call failed")
+	for _, f := range statusFields {
+		calls = append(calls, call{f.field.Cmd(), []interface{}{hash}})
 	}
-	s.Ratio = float64(results.([]interface{})[0].(int)) / float64(1000)
-	// Size
-	results, err = r.xmlrpcClient.Call("d.size_bytes", t.Hash)
+	calls = append(calls, detailedStateCalls(hash)...)
+	calls = append(calls, call{"d.tracker_size", []interface{}{hash}})
+
+	results, err := r.multicall(calls...)
 	if err != nil {
-		return s, errors.Wrap(err, "d.size_bytes XMLRPC call failed")
+		return TorrentSnapshot{}, err
 	}
-	s.Size = results.([]interface{})[0].(int)
+	s := TorrentSnapshot{Torrent: torrentFromRow(torrentFields, results)}
+	results = results[len(torrentFields):]
+	for i, f := range statusFields {
+		f.set(&s.Status, results[i])
+	}
+	results = results[len(statusFields):]
+	s.State = detailedStateFrom(results)
+	s.TrackerCount = asInt(results[len(results)-1])
 	return s, nil
 }
 
-// StartTorrent starts the torrent
+// StartTorrent starts the torrent (d.start), opening it first if needed
+// Started torrents are listed in ViewStarted.
 func (r *RTorrent) StartTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.start", t.Hash)
+	return r.StartTorrentContext(context.Background(), t)
+}
+
+// StartTorrentContext is like StartTorrent, the request is aborted when ctx is done
+func (r *RTorrent) StartTorrentContext(ctx context.Context, t Torrent) error {
+	_, err := r.callContext(ctx, "d.start", t.Hash)
 	if err != nil {
 		return errors.Wrap(err, "d.start XMLRPC call failed")
 	}
 	return nil
 }
 
-// StopTorrent stops the torrent
+// StopTorrent stops the torrent (d.stop), it stays open until closed with CloseTorrent
+// Stopped torrents are listed in ViewStopped.
 func (r *RTorrent) StopTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.stop", t.Hash)
+	return r.StopTorrentContext(context.Background(), t)
+}
+
+// StopTorrentContext is like StopTorrent, the request is aborted when ctx is done
+func (r *RTorrent) StopTorrentContext(ctx context.Context, t Torrent) error {
+	_, err := r.callContext(ctx, "d.stop", t.Hash)
 	if err != nil {
 		return errors.Wrap(err, "d.stop XMLRPC call failed")
 	}
 	return nil
 }
 
-// CloseTorrent closes the torrent
+// StartAll starts all the given torrents with a single system.multicall, see StartTorrent
+// A failure of some of the torrents doesn't stop the others, they are returned as a *BatchError.
+func (r *RTorrent) StartAll(ts []Torrent) error {
+	return r.batch(ts, "d.start")
+}
+
+// StopAll stops all the given torrents with a single system.multicall, see StopTorrent
+// A failure of some of the torrents doesn't stop the others, they are returned as a *BatchError.
+func (r *RTorrent) StopAll(ts []Torrent) error {
+	return r.batch(ts, "d.stop")
+}
+
+// CloseTorrent closes the torrent (d.close), which also stops it
+// Closing releases the file handles of the torrent, which matters when managing many torrents.
 func (r *RTorrent) CloseTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.close", t.Hash)
+	return r.callDiscard("d.close", t.Hash)
+}
+
+// OpenTorrent opens the torrent (d.open) without starting it
+func (r *RTorrent) OpenTorrent(t Torrent) error {
+	return r.callDiscard("d.open", t.Hash)
+}
+
+// PauseTorrent pauses the torrent (d.pause)
+// Unlike StopTorrent the torrent stays started and open, only its transfers are halted (d.is_active becomes 0).
+// Use ResumeTorrent to continue, see Pause to halt the transfers of all torrents.
+func (r *RTorrent) PauseTorrent(t Torrent) error {
+	return r.callDiscard("d.pause", t.Hash)
+}
+
+// ResumeTorrent resumes the torrent paused with PauseTorrent (d.resume)
+func (r *RTorrent) ResumeTorrent(t Torrent) error {
+	return r.callDiscard("d.resume", t.Hash)
+}
+
+// HasValidResume checks if rTorrent accepted the data of the torrent without needing a hash check
+// It reads d.is_hash_checked, which rTorrent sets once the data has been verified, either from fast-resume data it
+// found consistent with the files on disk or by hash checking. It is only a hint: resume data compares file sizes and
+// modification times, so a hash check is the only reliable verification of the data.
+// When the rTorrent build does not know d.is_hash_checked, false is returned without an error.
+func (r *RTorrent) HasValidResume(t Torrent) (bool, error) {
+	checked, err := r.callBool(DIsHashChecked.Cmd(), t.Hash)
+	if err != nil && isMethodNotFound(err) {
+		return false, nil
+	}
+	return checked, err
+}
+
+// CheckHash triggers a hash check of the data of the torrent (d.check_hash)
+// The torrent is queued for checking and listed in ViewHashing until the check is done, use GetHashingTorrents
+// to follow its progress.
+func (r *RTorrent) CheckHash(t Torrent) error {
+	return r.callDiscard("d.check_hash", t.Hash)
+}
+
+// IsActive checks if the torrent is active
+func (r *RTorrent) IsActive(t Torrent) (bool, error) {
+	// active = 1; inactive = 0
+	return r.callBool("d.is_active", t.Hash)
+}
+
+// IsOpen checks if the torrent is open (d.is_open)
+func (r *RTorrent) IsOpen(t Torrent) (bool, error) {
+	// open = 1; closed = 0
+	return r.callBool("d.is_open", t.Hash)
+}
+
+// State returns the state that the torrent is into
+// It returns: 0 for stopped, 1 for started/paused
+func (r *RTorrent) State(t Torrent) (int, error) {
+	return r.callInt("d.state", t.Hash)
+}
+
+// DetailedState returns the state of the torrent, read from d.is_open, d.state, d.is_active, d.hashing, d.complete and
+// d.message in a single call
+// The first matching rule determines the state:
+//   - DetailedStateChecking when d.hashing is not 0
+//   - DetailedStateErrored when d.message is not empty
+//   - DetailedStateStopped when d.is_open is 0, or d.state is 0 (stopped with StopTorrent but still open)
+//   - DetailedStateQueued when d.is_active is 0
+//   - DetailedStateSeeding when d.complete is 1
+//   - DetailedStateDownloading otherwise
+func (r *RTorrent) DetailedState(t Torrent) (DetailedState, error) {
+	results, err := r.multicall(detailedStateCalls(t.Hash)...)
+	if err != nil {
+		return DetailedStateStopped, err
+	}
+	return detailedStateFrom(results), nil
+}
+
+// detailedStateCalls are the calls which results are needed by detailedStateFrom
+func detailedStateCalls(hash string) []call {
+	return []call{
+		{DIsOpen.Cmd(), []interface{}{hash}},
+		{DIsActive.Cmd(), []interface{}{hash}},
+		{DHashing.Cmd(), []interface{}{hash}},
+		{DComplete.Cmd(), []interface{}{hash}},
+		{DMessage.Cmd(), []interface{}{hash}},
+		{DState.Cmd(), []interface{}{hash}},
+	}
+}
+
+// detailedStateFrom determines the state from the results of the detailedStateCalls, see DetailedState
+func detailedStateFrom(results []interface{}) DetailedState {
+	switch {
+	case asInt(results[2]) != 0:
+		return DetailedStateChecking
+	case asString(results[4]) != "":
+		return DetailedStateErrored
+	case asInt(results[0]) == 0, asInt(results[5]) == 0:
+		return DetailedStateStopped
+	case asInt(results[1]) == 0:
+		return DetailedStateQueued
+	case asInt(results[3]) == 1:
+		return DetailedStateSeeding
+	}
+	return DetailedStateDownloading
+}
+
+// InspectFields are the d.* commands queried by Inspect
+// It can be changed to add or remove commands, every command must accept a torrent hash as its only argument.
+var InspectFields = []Field{
+	DHash, DName, DLabel, DDirectory, DBasePath, DSessionFile,
+	DSizeInBytes, DCompletedBytes, DSizeChunks, DCompletedChunks, DComplete,
+	DIsOpen, DIsActive, DHashing, DIsHashChecked, DState, DMessage, DPriority,
+	DDownRate, DUpRate, DDownTotal, DUpTotal, DRatio,
+	DCreationTime, DStartedTime, DFinishedTime, DLoadDate,
+	"d.is_private", DPeersConnected, "d.tracker_size", DThrottleName,
+}
+
+// Inspect returns the value of every command in InspectFields for the torrent, keyed by command name (e.g. "d.name")
+// All of the commands are queried in a single system.multicall request. It is meant for debugging, use the dedicated
+// methods to read specific values.
+func (r *RTorrent) Inspect(t Torrent) (map[string]interface{}, error) {
+	calls := make([]call, 0, len(InspectFields))
+	for _, field := range InspectFields {
+		calls = append(calls, call{field.Cmd(), []interface{}{t.Hash}})
+	}
+	results, err := r.multicall(calls...)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(results))
+	for i, field := range InspectFields {
+		values[field.Cmd()] = results[i]
+	}
+	return values, nil
+}
+
+// SaveSession saves the session of all torrents to rTorrent's session directory
+// It returns ErrSessionNotConfigured when rTorrent has no session directory, since session.save silently does nothing then.
+// rTorrent offers no way to confirm the files were written, a returned nil only means session.save did not fault.
+func (r *RTorrent) SaveSession() error {
+	if err := r.checkSession(); err != nil {
+		return err
+	}
+	return r.callDiscard("session.save")
+}
+
+// SaveResume saves the session of the torrent to rTorrent's session directory (d.save_full_session)
+// This writes the same files as SaveSession (the torrent, its state and its resume data) for this torrent only,
+// e.g. right after changing its labels or file priorities so they survive a crash. rTorrent saves sessions
+// periodically on its own (every 20 minutes by default) and when it exits cleanly, so saving is only needed to
+// narrow that window. It returns ErrSessionNotConfigured when rTorrent has no session directory.
+func (r *RTorrent) SaveResume(t Torrent) error {
+	if err := r.checkSession(); err != nil {
+		return err
+	}
+	return r.callDiscard("d.save_full_session", t.Hash)
+}
+
+// SaveAllSessions saves the session of every torrent of the main view, like SaveResume does for one torrent
+// The sessions are saved with a single d.multicall2 request, use it after changing many torrents. SaveSession
+// (session.save) is the lighter command rTorrent runs periodically itself.
+func (r *RTorrent) SaveAllSessions() error {
+	if err := r.checkSession(); err != nil {
+		return err
+	}
+	return r.callDiscard("d.multicall2", "", string(ViewMain), "d.save_full_session=")
+}
+
+// checkSession returns ErrSessionNotConfigured when rTorrent has no session directory
+func (r *RTorrent) checkSession() error {
+	dir, err := r.callString("session.path")
 	if err != nil {
-		return errors.Wrap(err, "d.close XMLRPC call failed")
+		return err
+	}
+	if dir == "" {
+		return ErrSessionNotConfigured
 	}
 	return nil
 }
 
-// OpenTorrent opens the torrent
-func (r *RTorrent) OpenTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.open", t.Hash)
+// executeCaptureScript runs the command given as positional parameters and prints its output followed by its exit code
+const executeCaptureScript = `out=$("$@"); code=$?; printf '%s\n%d' "$out" "$code"`
+
+// ExecuteCapture runs the command on the rTorrent host and returns its standard output without trailing newlines
+// A non-zero exit code is returned as an error which includes the output.
+// The command and each of the args are passed as separate arguments to sh on the rTorrent host, they are never
+// interpreted by a shell so no quoting is needed.
+//
+// The command runs as the rTorrent user. Anyone able to reach the XMLRPC endpoint can run arbitrary commands
+// this way, never pass untrusted input as the command and protect the endpoint accordingly.
+func (r *RTorrent) ExecuteCapture(command string, args ...string) (string, error) {
+	params := []interface{}{"", "sh", "-c", executeCaptureScript, "sh", command}
+	for _, arg := range args {
+		params = append(params, arg)
+	}
+	output, err := r.callString("execute.capture", params...)
+	if err != nil {
+		return "", err
+	}
+	i := strings.LastIndex(output, "\n")
+	if i < 0 {
+		return "", errors.Errorf("unexpected execute.capture output: %q", output)
+	}
+	code, err := strconv.Atoi(output[i+1:])
+	if err != nil {
+		return "", errors.Errorf("unexpected execute.capture output: %q", output)
+	}
+	output = output[:i]
+	if code != 0 {
+		return output, errors.Errorf("%s exited with code %d: %s", command, code, output)
+	}
+	return output, nil
+}
+
+// SessionDirectory returns the directory where rTorrent stores its session (session.path), empty when not configured
+func (r *RTorrent) SessionDirectory() (string, error) {
+	return r.callString("session.path")
+}
+
+// DefaultDownloadDirectory returns the directory torrents are downloaded to unless set otherwise (directory.default)
+func (r *RTorrent) DefaultDownloadDirectory() (string, error) {
+	return r.callString("directory.default")
+}
+
+// FreeDiskSpace returns the space available on the filesystem of the default download directory (bytes)
+// rTorrent has no command for it, so it runs df on the rTorrent host with ExecuteCapture, which requires df to be
+// available to rTorrent. The session directory is used when no default download directory is set.
+func (r *RTorrent) FreeDiskSpace() (int64, error) {
+	results, err := r.multicall(
+		call{"directory.default", []interface{}{""}},
+		call{"session.path", []interface{}{""}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	dir := asString(results[0])
+	if dir == "" {
+		dir = asString(results[1])
+	}
+	if dir == "" {
+		return 0, errors.New("neither a default download directory nor a session directory is set")
+	}
+	// the POSIX format prints a header and a single line per filesystem, with the available 1024-byte blocks
+	// in the fourth column
+	output, err := r.ExecuteCapture("df", "-Pk", "--", dir)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(output, "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 4 {
+		return 0, errors.Errorf("unexpected df output: %q", output)
+	}
+	available, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("unexpected df output: %q", output)
+	}
+	return available * 1024, nil
+}
+
+// MoveDataPhysical moves the data of the torrent to newDir on the rTorrent host and points the torrent at it
+// The torrent is stopped and closed, its data is moved with mv (via execute.throw), its directory is set to newDir
+// and it is opened and started again if it was before. If stopping, closing or mv fails the torrent is restored in
+// its previous state and the data is left where it was. If setting the directory fails once the data was moved, the
+// data is moved back with mv and the torrent restored as well; the returned error then includes every failure, so
+// that a torrent whose data could not be moved back can be fixed by hand.
+//
+// The data directory of a multi file torrent keeps its name, even if it differs from the name of the torrent
+// (d.directory_base.set is used rather than d.directory.set, which would append the name of the torrent).
+// It assumes that mv is available to rTorrent and newDir already exists. newDir must be an absolute path within
+// rTorrent's default download directory (directory.default), anything else is refused.
+// No hash check is triggered: within the same filesystem mv only renames and keeps the modification times, which
+// rTorrent compares with its resume data when the torrent is opened. Use MoveDataPhysicalChecked for moves across
+// filesystems.
+func (r *RTorrent) MoveDataPhysical(t Torrent, newDir string) error {
+	return r.moveDataPhysical(t, newDir, false)
+}
+
+// MoveDataPhysicalChecked is like MoveDataPhysical, and hash checks the data once it is moved (d.check_hash)
+// mv copies the files when moving across filesystems, which may not keep their modification times. A torrent which
+// was closed is opened for the check.
+func (r *RTorrent) MoveDataPhysicalChecked(t Torrent, newDir string) error {
+	return r.moveDataPhysical(t, newDir, true)
+}
+
+func (r *RTorrent) moveDataPhysical(t Torrent, newDir string, check bool) error {
+	results, err := r.multicall(
+		call{"directory.default", []interface{}{""}},
+		call{DDirectory.Cmd(), []interface{}{t.Hash}},
+		call{DName.Cmd(), []interface{}{t.Hash}},
+		call{DIsMultiFile.Cmd(), []interface{}{t.Hash}},
+		call{DIsOpen.Cmd(), []interface{}{t.Hash}},
+		call{DState.Cmd(), []interface{}{t.Hash}},
+	)
+	if err != nil {
+		return err
+	}
+	defaultDir, directory, name := asString(results[0]), asString(results[1]), asString(results[2])
+	multiFile, wasOpen, wasStarted := asInt(results[3]) == 1, asInt(results[4]) == 1, asInt(results[5]) == 1
+
+	src := dataPath(directory, name, multiFile)
+	dst := path.Clean(newDir)
+	if !path.IsAbs(defaultDir) {
+		return errors.Errorf("default download directory %q is not an absolute path", defaultDir)
+	}
+	defaultDir = path.Clean(defaultDir)
+	if !path.IsAbs(dst) || (dst != defaultDir && !strings.HasPrefix(dst, defaultDir+"/")) {
+		return errors.Errorf("refusing to move to %q, it is not within the default download directory %q", newDir, defaultDir)
+	}
+	if dst == src || strings.HasPrefix(dst, src+"/") {
+		return errors.Errorf("refusing to move %q into itself", src)
+	}
+	if dst == path.Dir(src) {
+		return nil
+	}
+
+	if _, err := r.multicall(call{"d.stop", []interface{}{t.Hash}}, call{"d.close", []interface{}{t.Hash}}); err != nil {
+		if restoreErr := r.restoreState(t, wasOpen, wasStarted); restoreErr != nil {
+			return errors.Wrapf(err, "the torrent could not be restored (%v)", restoreErr)
+		}
+		return err
+	}
+	if err := r.callDiscard("execute.throw", "", "mv", "--", src, dst+"/"); err != nil {
+		if restoreErr := r.restoreState(t, wasOpen, wasStarted); restoreErr != nil {
+			return errors.Wrapf(restoreErr, "mv failed (%v) and the torrent could not be restored", err)
+		}
+		return err
+	}
+	moved := path.Join(dst, path.Base(src))
+	if multiFile {
+		err = r.callDiscard("d.directory_base.set", t.Hash, moved)
+	} else {
+		err = r.SetDirectory(t, dst)
+	}
 	if err != nil {
-		return errors.Wrap(err, "d.open XMLRPC call failed")
+		if _, mvErr := r.caller.Call("execute.throw", "", "mv", "--", moved, path.Dir(src)+"/"); mvErr != nil {
+			return errors.Wrapf(err, "moving %s back to %s failed (%v), the torrent was left stopped", moved, path.Dir(src), mvErr)
+		}
+		if restoreErr := r.restoreState(t, wasOpen, wasStarted); restoreErr != nil {
+			return errors.Wrapf(err, "the data was moved back but the torrent could not be restored (%v)", restoreErr)
+		}
+		return errors.Wrap(err, "the data was moved back")
+	}
+	if !check {
+		return r.restoreState(t, wasOpen, wasStarted)
+	}
+	if err := r.restoreState(t, true, wasStarted); err != nil {
+		return err
+	}
+	return r.CheckHash(t)
+}
+
+// GetDirectory returns the directory of the torrent (d.directory)
+// For multi file torrents it is the directory holding the files, for single file torrents the one holding the file.
+func (r *RTorrent) GetDirectory(t Torrent) (string, error) {
+	return r.callString(DDirectory.Cmd(), t.Hash)
+}
+
+// SetDirectory sets the directory of the torrent (d.directory.set)
+// It only changes where rTorrent looks for the data, the files are not moved: use MoveDataPhysical to move them
+// along, or move them with external tooling. rTorrent refuses to change the directory of an open torrent, so it must
+// be stopped and closed first (StopTorrent and CloseTorrent). For multi file torrents rTorrent appends the name of
+// the torrent to path, like when it is added; d.directory_base.set is the command setting the directory as is.
+func (r *RTorrent) SetDirectory(t Torrent, path string) error {
+	return r.callDiscard("d.directory.set", t.Hash, path)
+}
+
+// dataPath returns the path of the data of a torrent from its d.directory, d.name and d.is_multi_file
+// The data is the directory of multi file torrents, or the file named after the torrent within it otherwise.
+func dataPath(directory, name string, multiFile bool) string {
+	if multiFile {
+		return directory
+	}
+	return path.Join(directory, name)
+}
+
+// restoreState opens and starts the torrent again as it was
+func (r *RTorrent) restoreState(t Torrent, open, started bool) error {
+	switch {
+	case started:
+		return r.StartTorrent(t)
+	case open:
+		return r.OpenTorrent(t)
 	}
 	return nil
 }
 
-// PauseTorrent pauses the torrent
-func (r *RTorrent) PauseTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.pause", t.Hash)
+// ExportAll returns the .torrent file data of all torrents in the view, keyed by hash
+// The files are read from rTorrent's session directory with a single batched execute.capture of base64, so the
+// base64 utility must be available to rTorrent. The whole result is held in memory at once (plus the base64
+// encoded response while it is decoded), use ExportEach for very large instances.
+func (r *RTorrent) ExportAll(view View) (map[string][]byte, error) {
+	files, err := r.sessionFiles(view)
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]call, 0, len(files))
+	for _, f := range files {
+		calls = append(calls, call{"execute.capture", []interface{}{"", "base64", f[1]}})
+	}
+	results, err := r.multicall(calls...)
 	if err != nil {
-		return errors.Wrap(err, "d.pause XMLRPC call failed")
+		return nil, err
+	}
+	exported := make(map[string][]byte, len(files))
+	for i, f := range files {
+		data, err := base64.StdEncoding.DecodeString(asString(results[i]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode torrent %s", f[0])
+		}
+		exported[f[0]] = data
+	}
+	return exported, nil
+}
+
+// ExportEach calls fn with the .torrent file data of every torrent in the view, one torrent at a time
+// It makes one request per torrent so only a single torrent file is held in memory at once.
+// Returning an error from fn stops the export and returns that error. See ExportAll for the requirements.
+func (r *RTorrent) ExportEach(view View, fn func(hash string, data []byte) error) error {
+	files, err := r.sessionFiles(view)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		output, err := r.ExecuteCapture("base64", f[1])
+		if err != nil {
+			return errors.Wrapf(err, "failed to read torrent %s", f[0])
+		}
+		data, err := base64.StdEncoding.DecodeString(output)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode torrent %s", f[0])
+		}
+		if err := fn(f[0], data); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// ResumeTorrent resumes the torrent
-func (r *RTorrent) ResumeTorrent(t Torrent) error {
-	_, err := r.xmlrpcClient.Call("d.resume", t.Hash)
+// sessionFiles returns the hash and session file path of every torrent in the view
+func (r *RTorrent) sessionFiles(view View) ([][2]string, error) {
+	args := []interface{}{"", string(view), DHash.Query(), DSessionFile.Query()}
+	rows, err := r.callList("d.multicall2", args...)
+	if err != nil {
+		return nil, err
+	}
+	var files [][2]string
+	for i, result := range rows {
+		torrentData := asList(result)
+		if len(torrentData) < 2 {
+			return nil, errors.Errorf("unexpected d.multicall2 row %d: %v", i, result)
+		}
+		hash, path := asString(torrentData[0]), asString(torrentData[1])
+		if path == "" {
+			return nil, errors.Errorf("torrent %s has no session file", hash)
+		}
+		files = append(files, [2]string{hash, path})
+	}
+	return files, nil
+}
+
+// call is a single method call batched by multicall
+type call struct {
+	method string
+	args   []interface{}
+}
+
+// multicall issues the calls in a single system.multicall request and returns their results in order
+// A fault of any of the calls is returned as an error.
+func (r *RTorrent) multicall(calls ...call) ([]interface{}, error) {
+	return r.multicallContext(context.Background(), calls...)
+}
+
+func (r *RTorrent) multicallContext(ctx context.Context, calls ...call) ([]interface{}, error) {
+	batch := make([]xmlrpc.Call, 0, len(calls))
+	for _, c := range calls {
+		batch = append(batch, xmlrpc.Call{Name: c.method, Args: c.args})
+	}
+	values, err := xmlrpc.MultiCallWith(ctx, r.callContext, batch)
 	if err != nil {
-		return errors.Wrap(err, "d.resume XMLRPC call failed")
+		return nil, errors.Wrap(err, "system.multicall XMLRPC call failed")
+	}
+	for i, value := range values {
+		if fault, ok := value.(xmlrpc.Fault); ok {
+			return nil, errors.Wrapf(fault, "%s XMLRPC call failed", calls[i].method)
+		}
+	}
+	return values, nil
+}
+
+// batch calls the method on each of the torrents, with the hash followed by args, in a single system.multicall
+// The faults of the calls are collected in a *BatchError rather than aborting the batch.
+func (r *RTorrent) batch(ts []Torrent, method string, args ...interface{}) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	calls := make([]xmlrpc.Call, 0, len(ts))
+	for _, t := range ts {
+		calls = append(calls, xmlrpc.Call{Name: method, Args: append([]interface{}{t.Hash}, args...)})
+	}
+	values, err := xmlrpc.MultiCallWith(context.Background(), r.callContext, calls)
+	if err != nil {
+		return errors.Wrap(err, "system.multicall XMLRPC call failed")
+	}
+	failed := make(map[string]error)
+	for i, value := range values {
+		if fault, ok := value.(xmlrpc.Fault); ok {
+			failed[ts[i].Hash] = errors.Wrapf(fault, "%s XMLRPC call failed", method)
+		}
+	}
+	if len(failed) > 0 {
+		return &BatchError{Errors: failed}
 	}
 	return nil
 }
 
-// IsActive checks if the torrent is active
-func (r *RTorrent) IsActive(t Torrent) (bool, error) {
-	results, err := r.xmlrpcClient.Call("d.is_active", t.Hash)
+// retryCaller retries the calls of caller failing with a network error, see WithRetry
+type retryCaller struct {
+	caller   Caller
+	attempts int
+	backoff  time.Duration
+}
+
+func (c *retryCaller) Call(name string, args ...interface{}) (interface{}, error) {
+	return c.CallContext(context.Background(), name, args...)
+}
+
+func (c *retryCaller) CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	delay := c.backoff
+	for attempt := 1; ; attempt++ {
+		var result interface{}
+		var err error
+		if caller, ok := c.caller.(contextCaller); ok {
+			result, err = caller.CallContext(ctx, name, args...)
+		} else {
+			result, err = c.caller.Call(name, args...)
+		}
+		if err == nil || attempt >= c.attempts || !isNetworkError(err) || ctx.Err() != nil {
+			return result, err
+		}
+		// wait between half and all of the delay, so that clients failing together don't retry together
+		wait := delay / 2
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(delay - wait)))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// isNetworkError checks if the call failed to reach rTorrent or to get its response
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// callContext makes a call with the caller, aborted when ctx is done if the caller supports it, see Caller
+func (r *RTorrent) callContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	if caller, ok := r.caller.(contextCaller); ok {
+		return caller.CallContext(ctx, name, args...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.caller.Call(name, args...)
+}
+
+// isMethodNotFound checks if the error is the fault returned for a method rTorrent does not know
+func isMethodNotFound(err error) bool {
+	var fault xmlrpc.Fault
+	return errors.As(err, &fault) && fault.Code == -506
+}
+
+// getVariable returns the value of the string variable created by setVariable, empty if it doesn't exist yet
+func (r *RTorrent) getVariable(name string) (string, error) {
+	value, err := r.callString(name)
+	if err != nil && isMethodNotFound(err) {
+		return "", nil
+	}
+	return value, err
+}
+
+// setVariable sets the string variable on the server, creating it with method.insert the first time
+// The variable is shared by all of the clients of the rTorrent instance, but isn't kept across restarts.
+func (r *RTorrent) setVariable(name, value string) error {
+	err := r.callDiscard(name+".set", "", value)
+	if err == nil || !isMethodNotFound(err) {
+		return err
+	}
+	return r.callDiscard("method.insert", "", name, "string", value)
+}
+
+// callValue calls the method, which returns a single value, and returns it
+// The error of a failed call is wrapped with the name of the method, like the other call helpers do.
+func (r *RTorrent) callValue(method string, args ...interface{}) (interface{}, error) {
+	results, err := r.caller.Call(method, args...)
 	if err != nil {
-		return false, errors.Wrap(err, "d.is_active XMLRPC call failed")
+		return nil, errors.Wrap(err, method+" XMLRPC call failed")
 	}
-	// active = 1; inactive = 0
-	return results.([]interface{})[0].(int) == 1, nil
+	params, ok := results.([]interface{})
+	if !ok || len(params) == 0 {
+		return nil, errors.Errorf("%s returned no result", method)
+	}
+	return params[0], nil
 }
 
-// IsOpen checks if the torrent is open
-func (r *RTorrent) IsOpen(t Torrent) (bool, error) {
-	results, err := r.xmlrpcClient.Call("d.is_open", t.Hash)
+// callString calls the method and returns its result, which must be a string
+func (r *RTorrent) callString(method string, args ...interface{}) (string, error) {
+	result, err := r.callValue(method, args...)
 	if err != nil {
-		return false, errors.Wrap(err, "d.is_open XMLRPC call failed")
+		return "", err
 	}
-	// open = 1; closed = 0
-	return results.([]interface{})[0].(int) == 1, nil
+	s, ok := result.(string)
+	if !ok {
+		return "", errors.Errorf("%s result isn't string: %v", method, result)
+	}
+	return s, nil
 }
 
-// State returns the state that the torrent is into
-// It returns: 0 for stopped, 1 for started/paused
-func (r *RTorrent) State(t Torrent) (int, error) {
-	results, err := r.xmlrpcClient.Call("d.state", t.Hash)
+// callInt64 calls the method and returns its result, which must be an integer
+func (r *RTorrent) callInt64(method string, args ...interface{}) (int64, error) {
+	result, err := r.callValue(method, args...)
 	if err != nil {
-		return 0, errors.Wrap(err, "d.state XMLRPC call failed")
+		return 0, err
 	}
-	return results.([]interface{})[0].(int), nil
+	i, ok := intValue(result)
+	if !ok {
+		return 0, errors.Errorf("%s result isn't int: %v", method, result)
+	}
+	return i, nil
+}
+
+// callInt is like callInt64, for values which fit in an int like rates and counts
+func (r *RTorrent) callInt(method string, args ...interface{}) (int, error) {
+	i, err := r.callInt64(method, args...)
+	return int(i), err
+}
+
+// callBool calls the method and returns its result, which must be a boolean or an integer like rTorrent returns
+// for them (1 for true, 0 for false)
+func (r *RTorrent) callBool(method string, args ...interface{}) (bool, error) {
+	result, err := r.callValue(method, args...)
+	if err != nil {
+		return false, err
+	}
+	if b, ok := result.(bool); ok {
+		return b, nil
+	}
+	i, ok := intValue(result)
+	if !ok {
+		return false, errors.Errorf("%s result isn't bool: %v", method, result)
+	}
+	return i != 0, nil
+}
+
+// callList calls the method and returns its result, which must be a list, e.g. the rows of d.multicall2
+func (r *RTorrent) callList(method string, args ...interface{}) ([]interface{}, error) {
+	result, err := r.callValue(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s result isn't a list: %v", method, result)
+	}
+	return list, nil
+}
+
+// callDiscard calls the method for its effect, e.g. a setter, and discards its result
+func (r *RTorrent) callDiscard(method string, args ...interface{}) error {
+	if _, err := r.caller.Call(method, args...); err != nil {
+		return errors.Wrap(err, method+" XMLRPC call failed")
+	}
+	return nil
+}
+
+// intValue returns the value as an int64 if it is an integer, which the XMLRPC parser returns as int or int64 (i8)
+func intValue(v interface{}) (int64, bool) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), true
+	case int64:
+		return i, true
+	}
+	return 0, false
+}
+
+// asInt returns the value as an int, or 0 if it isn't an integer
+func asInt(v interface{}) int {
+	i, _ := intValue(v)
+	return int(i)
+}
+
+// asInt64 returns the value as an int64, or 0 if it isn't an integer
+// It is used for sizes and totals, which exceed the range of int on 32-bit platforms.
+func asInt64(v interface{}) int64 {
+	i, _ := intValue(v)
+	return i
+}
+
+// asTime returns the value as a time from epoch seconds, or the zero time if it is 0 or isn't an integer
+func asTime(v interface{}) time.Time {
+	if seconds := asInt64(v); seconds != 0 {
+		return time.Unix(seconds, 0)
+	}
+	return time.Time{}
+}
+
+// asString returns the value as a string, or "" if it isn't a string
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// asList returns the value as a list, or nil if it isn't a list
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
 }
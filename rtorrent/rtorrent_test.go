@@ -1,10 +1,23 @@
 package rtorrent
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/mrobinsn/go-rtorrent/torrent"
+	"github.com/mrobinsn/go-rtorrent/xmlrpc"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -23,12 +36,144 @@ func TestRTorrent(t *testing.T) {
 		// Don't assert anything about the response, differs based upon the environment
 	})
 
+	t.Run("set bind address", func(t *testing.T) {
+		original, err := client.IP()
+		require.NoError(t, err)
+		defer func() {
+			if original != "" {
+				require.NoError(t, client.SetBindAddress(original))
+			}
+		}()
+
+		err = client.SetBindAddress("0.0.0.0")
+		require.NoError(t, err)
+
+		ip, err := client.IP()
+		require.NoError(t, err)
+		require.Equal(t, "0.0.0.0", ip)
+
+		err = client.SetBindAddress("not-an-ip")
+		require.Error(t, err)
+	})
+
 	t.Run("get name", func(t *testing.T) {
 		name, err := client.Name()
 		require.NoError(t, err)
 		require.NotEmpty(t, name)
 	})
 
+	t.Run("versions", func(t *testing.T) {
+		semver := regexp.MustCompile(`^\d+\.\d+\.\d+`)
+		clientVersion, err := client.ClientVersion()
+		require.NoError(t, err)
+		require.Regexp(t, semver, clientVersion)
+		libraryVersion, err := client.LibraryVersion()
+		require.NoError(t, err)
+		require.Regexp(t, semver, libraryVersion)
+
+		versions, err := client.Versions()
+		require.NoError(t, err)
+		require.Equal(t, Versions{Client: clientVersion, Library: libraryVersion}, versions)
+	})
+
+	t.Run("capabilities", func(t *testing.T) {
+		version, err := client.APIVersion()
+		require.NoError(t, err)
+		require.NotEmpty(t, version)
+
+		supported, err := client.SupportsMethod("d.multicall2")
+		require.NoError(t, err)
+		require.True(t, supported)
+		supported, err = client.SupportsMethod("d.no_such_method")
+		require.NoError(t, err)
+		require.False(t, supported)
+	})
+
+	t.Run("directories", func(t *testing.T) {
+		session, err := client.SessionDirectory()
+		require.NoError(t, err)
+		require.NotEmpty(t, session)
+		downloads, err := client.DefaultDownloadDirectory()
+		require.NoError(t, err)
+		require.NotEmpty(t, downloads)
+	})
+
+	t.Run("free disk space", func(t *testing.T) {
+		free, err := client.FreeDiskSpace()
+		require.NoError(t, err)
+		require.True(t, free > 0, "expected free disk space, got %d", free)
+	})
+
+	t.Run("toggle peer exchange", func(t *testing.T) {
+		err := client.SetPeerExchange(false)
+		require.NoError(t, err)
+		enabled, err := client.PeerExchange()
+		require.NoError(t, err)
+		require.False(t, enabled)
+
+		err = client.SetPeerExchange(true)
+		require.NoError(t, err)
+		enabled, err = client.PeerExchange()
+		require.NoError(t, err)
+		require.True(t, enabled)
+	})
+
+	t.Run("server time", func(t *testing.T) {
+		now, err := client.ServerTime()
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), now, time.Minute)
+	})
+
+	t.Run("server identity", func(t *testing.T) {
+		identity, err := client.ServerIdentity()
+		require.NoError(t, err)
+		again, err := client.ServerIdentity()
+		require.NoError(t, err)
+		require.Equal(t, identity, again)
+	})
+
+	t.Run("set encryption mode", func(t *testing.T) {
+		require.Error(t, client.SetEncryptionMode("always"))
+		require.NoError(t, client.SetEncryptionMode("allow_incoming,try_outgoing,enable_retry"))
+		mode, err := client.EncryptionMode()
+		require.NoError(t, err)
+		require.Equal(t, "allow_incoming,try_outgoing,enable_retry", mode)
+	})
+
+	t.Run("set dht mode", func(t *testing.T) {
+		require.Error(t, client.SetDHTMode("sometimes"))
+		require.NoError(t, client.SetDHTMode("auto"))
+	})
+
+	t.Run("group rate limits", func(t *testing.T) {
+		require.NoError(t, client.SetSeedingRateLimit(100*1024))
+		require.NoError(t, client.SetLeechingRateLimit(200*1024))
+
+		seeding, err := client.SeedingRateLimit()
+		require.NoError(t, err)
+		require.Equal(t, 100*1024, seeding)
+		leeching, err := client.LeechingRateLimit()
+		require.NoError(t, err)
+		require.Equal(t, 200*1024, leeching)
+
+		require.NoError(t, client.SetSeedingRateLimit(0))
+		require.NoError(t, client.SetLeechingRateLimit(0))
+	})
+
+	t.Run("global rate limits", func(t *testing.T) {
+		require.NoError(t, client.SetGlobalDownRate(1024*1024))
+		require.NoError(t, client.SetGlobalUpRate(1024*1024))
+		down, err := client.GlobalDownMaxRate()
+		require.NoError(t, err)
+		require.Equal(t, 1024*1024, down)
+		up, err := client.GlobalUpMaxRate()
+		require.NoError(t, err)
+		require.Equal(t, 1024*1024, up)
+
+		require.NoError(t, client.SetGlobalDownRate(0))
+		require.NoError(t, client.SetGlobalUpRate(0))
+	})
+
 	t.Run("down total", func(t *testing.T) {
 		total, err := client.DownTotal()
 		require.NoError(t, err)
@@ -41,6 +186,16 @@ func TestRTorrent(t *testing.T) {
 		require.Zero(t, total, "expected no data to be transferred yet")
 	})
 
+	t.Run("session totals", func(t *testing.T) {
+		down, err := client.SessionDownTotal()
+		require.NoError(t, err)
+		require.Zero(t, down, "expected no data to be transferred yet")
+
+		up, err := client.SessionUpTotal()
+		require.NoError(t, err)
+		require.Zero(t, up, "expected no data to be transferred yet")
+	})
+
 	t.Run("down rate", func(t *testing.T) {
 		rate, err := client.DownRate()
 		require.NoError(t, err)
@@ -53,6 +208,37 @@ func TestRTorrent(t *testing.T) {
 		require.Zero(t, rate, "expected no upload yet")
 	})
 
+	t.Run("save session", func(t *testing.T) {
+		err := client.SaveSession()
+		require.NoError(t, err)
+	})
+
+	t.Run("no active torrents", func(t *testing.T) {
+		downloading, seeding, err := client.ActiveCounts()
+		require.NoError(t, err)
+		require.Zero(t, downloading)
+		require.Zero(t, seeding)
+	})
+
+	t.Run("no peers", func(t *testing.T) {
+		total, err := client.TotalPeers()
+		require.NoError(t, err)
+		require.Zero(t, total)
+	})
+
+	t.Run("no hashing torrents", func(t *testing.T) {
+		torrents, err := client.GetHashingTorrents()
+		require.NoError(t, err)
+		require.Empty(t, torrents)
+	})
+
+	t.Run("get views", func(t *testing.T) {
+		views, err := client.GetViews()
+		require.NoError(t, err)
+		require.Contains(t, views, "main")
+		require.Contains(t, views, "default")
+	})
+
 	t.Run("get no torrents", func(t *testing.T) {
 		torrents, err := client.GetTorrents(ViewMain)
 		require.NoError(t, err)
@@ -85,7 +271,7 @@ func TestRTorrent(t *testing.T) {
 				require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", torrents[0].Hash)
 				require.Equal(t, "Fedora-i3-Live-x86_64-35", torrents[0].Name)
 				require.Equal(t, "", torrents[0].Label)
-				require.Equal(t, 1437206706, torrents[0].Size)
+				require.Equal(t, int64(1437206706), torrents[0].Size)
 				require.Equal(t, "/downloads/temp/Fedora-i3-Live-x86_64-35", torrents[0].Path)
 				require.False(t, torrents[0].Completed)
 
@@ -97,9 +283,45 @@ func TestRTorrent(t *testing.T) {
 					for _, f := range files {
 						require.NotEmpty(t, f.Path)
 						require.NotZero(t, f.Size)
+						require.Equal(t, FilePriorityNormal, f.Priority)
+						require.NotZero(t, f.SizeChunks)
+						require.True(t, f.PercentComplete() >= 0 && f.PercentComplete() <= 100, f.Pretty())
+					}
+				})
+
+				t.Run("set file priority", func(t *testing.T) {
+					require.NoError(t, client.SetFilePriority(torrents[0], 0, FilePriorityOff))
+					require.NoError(t, client.UpdatePriorities(torrents[0]))
+					files, err := client.GetFiles(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, FilePriorityOff, files[0].Priority)
+
+					require.NoError(t, client.SetFilePriority(torrents[0], 0, FilePriorityNormal))
+					require.NoError(t, client.UpdatePriorities(torrents[0]))
+				})
+
+				t.Run("get trackers", func(t *testing.T) {
+					trackers, err := client.GetTrackers(torrents[0])
+					require.NoError(t, err)
+					require.NotEmpty(t, trackers)
+					for _, tracker := range trackers {
+						require.NotEmpty(t, tracker.URL)
+						require.NotZero(t, tracker.Type)
 					}
 				})
 
+				t.Run("wanted size", func(t *testing.T) {
+					size, err := client.WantedSize(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, int64(torrents[0].Size), size, "expected all files to be wanted")
+				})
+
+				t.Run("is partially selected", func(t *testing.T) {
+					partial, err := client.IsPartiallySelected(torrents[0])
+					require.NoError(t, err)
+					require.False(t, partial, "expected all files to be selected by default")
+				})
+
 				t.Run("single get", func(t *testing.T) {
 					torrent, err := client.GetTorrent(torrents[0].Hash)
 					require.NoError(t, err)
@@ -107,6 +329,11 @@ func TestRTorrent(t *testing.T) {
 					require.NotEmpty(t, torrent.Name)
 					require.NotEmpty(t, torrent.Path)
 					require.NotEmpty(t, torrent.Size)
+					require.Equal(t, "Fedora-i3-Live-x86_64-35", torrent.Name)
+					require.Equal(t, int64(1437206706), torrent.Size)
+					require.Equal(t, torrents[0].Path, torrent.Path)
+					require.Equal(t, torrents[0].Created, torrent.Created)
+					require.Equal(t, torrents[0].Started, torrent.Started)
 				})
 
 				t.Run("change label", func(t *testing.T) {
@@ -130,6 +357,52 @@ func TestRTorrent(t *testing.T) {
 					require.Equal(t, "TestLabel", torrents[0].Label)
 				})
 
+				t.Run("tags", func(t *testing.T) {
+					defer func() { require.NoError(t, client.SetLabel(torrents[0], "TestLabel")) }()
+					require.NoError(t, client.SetTags(torrents[0], []string{"tv", "my show"}))
+					tags, err := client.GetTags(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, []string{"tv", "my show"}, tags)
+
+					labels, err := client.GetLabels()
+					require.NoError(t, err)
+					require.Equal(t, []string{"tv,my%20show"}, labels)
+				})
+
+				t.Run("custom field", func(t *testing.T) {
+					require.NoError(t, client.SetCustom(torrents[0], 3, "TestGroup"))
+					value, err := client.GetCustom(torrents[0], 3)
+					require.NoError(t, err)
+					require.Equal(t, "TestGroup", value)
+				})
+
+				t.Run("custom key", func(t *testing.T) {
+					value, err := client.GetCustomKey(torrents[0], "test_key")
+					require.NoError(t, err)
+					require.Empty(t, value)
+
+					require.NoError(t, client.SetCustomKey(torrents[0], "test_key", `{"a": "<b> & c"}`))
+					value, err = client.GetCustomKey(torrents[0], "test_key")
+					require.NoError(t, err)
+					require.Equal(t, `{"a": "<b> & c"}`, value)
+				})
+
+				t.Run("change priority", func(t *testing.T) {
+					err := client.SetPriority(torrents[0], PriorityHigh)
+					require.NoError(t, err)
+
+					priority, err := client.GetPriority(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, PriorityHigh, priority)
+
+					listed, err := client.GetTorrents(ViewMain)
+					require.NoError(t, err)
+					require.Len(t, listed, 1)
+					require.Equal(t, PriorityHigh, listed[0].Priority)
+
+					require.NoError(t, client.SetPriority(torrents[0], PriorityNormal))
+				})
+
 				t.Run("get status", func(t *testing.T) {
 					var status Status
 					var err error
@@ -152,6 +425,11 @@ func TestRTorrent(t *testing.T) {
 					require.NotZero(t, status.CompletedBytes)
 					require.NotZero(t, status.DownRate)
 					require.NotZero(t, status.Size)
+					require.NotZero(t, status.SizeChunks)
+					require.NotZero(t, status.CompletedChunks)
+					require.True(t, status.CompletedChunks < status.SizeChunks, "expected a partially downloaded torrent")
+					require.NotZero(t, status.PeersConnected, "expected peers while downloading")
+					require.True(t, status.SeedersConnected >= 0 && status.LeechersConnected >= 0)
 					// require.NotZero(t, status.UpRate)
 					//require.NotZero(t, status.Ratio)
 				})
@@ -213,9 +491,13 @@ func TestRTorrent(t *testing.T) {
 				require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", torrents[0].Hash)
 				require.Equal(t, "Fedora-i3-Live-x86_64-35", torrents[0].Name)
 				require.Equal(t, label.Value, torrents[0].Label)
-				require.Equal(t, 1437206706, torrents[0].Size)
+				require.Equal(t, int64(1437206706), torrents[0].Size)
 				require.Equal(t, "/downloads/temp/Fedora-i3-Live-x86_64-35", torrents[0].Path)
 				require.False(t, torrents[0].Completed)
+				require.Equal(t, StateStopped, torrents[0].State)
+				require.Empty(t, torrents[0].Message)
+				require.False(t, torrents[0].AddedAt.IsZero())
+				require.True(t, torrents[0].FinishedAt.IsZero())
 
 				t.Run("get status", func(t *testing.T) {
 					<-time.After(time.Second)
@@ -227,6 +509,30 @@ func TestRTorrent(t *testing.T) {
 					require.Zero(t, status.CompletedBytes)
 					require.Zero(t, status.DownRate)
 					require.NotZero(t, status.Size)
+					require.NotZero(t, status.SizeChunks)
+					require.True(t, status.CompletedChunks <= status.SizeChunks)
+					require.Equal(t, int64(262144), status.ChunkSize)
+					require.True(t, status.PeersConnected >= 0)
+					require.True(t, status.SeedersConnected >= 0)
+					require.True(t, status.LeechersConnected >= 0)
+				})
+
+				t.Run("set directory", func(t *testing.T) {
+					require.NoError(t, client.StopTorrent(torrents[0]))
+					require.NoError(t, client.CloseTorrent(torrents[0]))
+					original, err := client.GetDirectory(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, torrents[0].Path, original)
+
+					require.NoError(t, client.SetDirectory(torrents[0], "/downloads/moved"))
+					directory, err := client.GetDirectory(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, "/downloads/moved/Fedora-i3-Live-x86_64-35", directory)
+
+					require.NoError(t, client.SetDirectory(torrents[0], path.Dir(original)))
+					directory, err = client.GetDirectory(torrents[0])
+					require.NoError(t, err)
+					require.Equal(t, original, directory)
 				})
 
 				t.Run("start torrent", func(t *testing.T) {
@@ -262,6 +568,17 @@ func TestRTorrent(t *testing.T) {
 						require.Equal(t, 1, state)
 					})
 
+					t.Run("check if in started view", func(t *testing.T) {
+						started, err := client.GetTorrents(ViewStarted)
+						require.NoError(t, err)
+						require.Len(t, started, 1)
+						require.Equal(t, torrents[0].Hash, started[0].Hash)
+
+						stopped, err := client.GetTorrents(ViewStopped)
+						require.NoError(t, err)
+						require.Empty(t, stopped)
+					})
+
 					// wait some seconds to properly start to download bytes so
 					// to allow testing for up/down total post activity
 					<-time.After(time.Second * 10)
@@ -358,6 +675,17 @@ func TestRTorrent(t *testing.T) {
 						require.False(t, isActive)
 						require.Equal(t, 0, state)
 					})
+
+					t.Run("check if in stopped view", func(t *testing.T) {
+						stopped, err := client.GetTorrents(ViewStopped)
+						require.NoError(t, err)
+						require.Len(t, stopped, 1)
+						require.Equal(t, torrents[0].Hash, stopped[0].Hash)
+
+						started, err := client.GetTorrents(ViewStarted)
+						require.NoError(t, err)
+						require.Empty(t, started)
+					})
 				})
 
 				t.Run("close torrent", func(t *testing.T) {
@@ -510,7 +838,7 @@ func TestRTorrent(t *testing.T) {
 				require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", torrents[0].Hash)
 				require.Equal(t, "Fedora-i3-Live-x86_64-35", torrents[0].Name)
 				require.Equal(t, "", torrents[0].Label)
-				require.Equal(t, 1437206706, torrents[0].Size)
+				require.Equal(t, int64(1437206706), torrents[0].Size)
 				require.Equal(t, "/downloads/temp/Fedora-i3-Live-x86_64-35", torrents[0].Path)
 				require.False(t, torrents[0].Completed)
 
@@ -525,6 +853,38 @@ func TestRTorrent(t *testing.T) {
 					}
 				})
 
+				t.Run("save torrent session", func(t *testing.T) {
+					require.NoError(t, client.SaveResume(torrents[0]))
+					require.NoError(t, client.SaveAllSessions())
+				})
+
+				t.Run("get peers", func(t *testing.T) {
+					peers, err := client.GetPeers(torrents[0])
+					require.NoError(t, err)
+					require.NotNil(t, peers)
+				})
+
+				t.Run("multicall", func(t *testing.T) {
+					results, err := client.xmlrpcClient.MultiCall([]xmlrpc.Call{
+						{Name: DName.Cmd(), Args: []interface{}{torrents[0].Hash}},
+						{Name: DSizeInBytes.Cmd(), Args: []interface{}{torrents[0].Hash}},
+					})
+					require.NoError(t, err)
+					require.Equal(t, []interface{}{"Fedora-i3-Live-x86_64-35", 1437206706}, results)
+				})
+
+				t.Run("size field", func(t *testing.T) {
+					results, err := client.xmlrpcClient.Call("d.multicall2", "", string(ViewMain), DHash.Query(), DSizeInBytes.Query())
+					require.NoError(t, err)
+					rows := results.([]interface{})[0].([]interface{})
+					require.Len(t, rows, 1)
+					require.Equal(t, []interface{}{"299939CFF841ED7FFCA2B3C2A35711C12589632B", 1437206706}, rows[0])
+				})
+
+				t.Run("check hash", func(t *testing.T) {
+					require.NoError(t, client.CheckHash(torrents[0]))
+				})
+
 				t.Run("delete torrent", func(t *testing.T) {
 					err := client.Delete(torrents[0])
 					require.NoError(t, err)
@@ -555,6 +915,93 @@ func TestRTorrent(t *testing.T) {
 			})
 		})
 
+		t.Run("with data (checked)", func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+			require.NoError(t, err)
+			require.NotEmpty(t, b)
+
+			err = client.AddTorrentChecked(b, "0000000000000000000000000000000000000000", false)
+			require.Error(t, err, "expected a mismatching hash to be rejected")
+
+			torrents, err := client.GetTorrents(ViewMain)
+			require.NoError(t, err)
+			require.Empty(t, torrents)
+		})
+
+		t.Run("with data (delete data)", func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+			require.NoError(t, err)
+
+			// nothing is downloaded while stopped, so create the data directory
+			data := "/downloads/temp/Fedora-i3-Live-x86_64-35"
+			_, err = client.ExecuteCapture("mkdir", "-p", data)
+			require.NoError(t, err)
+			hash, err := client.AddReturningHash(b)
+			require.NoError(t, err)
+
+			// It will take some time to appear, so retry a few times
+			var torrents []Torrent
+			for i := 0; i <= maxRetries; i++ {
+				<-time.After(time.Second)
+				torrents, err = client.GetTorrents(ViewMain)
+				require.NoError(t, err)
+				if len(torrents) > 0 {
+					break
+				}
+				if i == maxRetries {
+					require.NoError(t, errors.Errorf("torrent did not show up in time"))
+				}
+			}
+			require.Len(t, torrents, 1)
+			require.Equal(t, hash, torrents[0].Hash)
+			require.Equal(t, data, torrents[0].Path)
+
+			require.NoError(t, client.DeleteWithData(torrents[0]))
+			_, err = client.ExecuteCapture("test", "-e", data)
+			require.Error(t, err, "expected the data to be deleted")
+			torrents, err = client.GetTorrents(ViewMain)
+			require.NoError(t, err)
+			require.Empty(t, torrents)
+		})
+
+		t.Run("bulk label", func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+			require.NoError(t, err)
+			require.NoError(t, client.AddTorrentStopped(b))
+			require.NoError(t, client.AddMagnetStopped(bigBuckBunnyMagnet))
+
+			// It will take some time to appear, so retry a few times
+			var torrents []Torrent
+			for i := 0; i <= maxRetries; i++ {
+				<-time.After(time.Second)
+				torrents, err = client.GetTorrents(ViewMain)
+				require.NoError(t, err)
+				if len(torrents) == 2 {
+					break
+				}
+				if i == maxRetries {
+					require.NoError(t, errors.Errorf("torrents did not show up in time"))
+				}
+			}
+
+			require.NoError(t, client.SetLabelAll(torrents, "bulk-label"))
+			torrents, err = client.GetTorrents(ViewMain)
+			require.NoError(t, err)
+			require.Len(t, torrents, 2)
+
+			// neither is downloaded
+			completed, err := client.GetTorrentsFiltered(ViewMain, "d.complete=")
+			require.NoError(t, err)
+			require.Empty(t, completed)
+			labeled, err := client.GetTorrentsFiltered(ViewMain, "equal={d.custom1=,cat=bulk-label}")
+			require.NoError(t, err)
+			require.Len(t, labeled, 2)
+			for _, tor := range torrents {
+				require.Equal(t, "bulk-label", tor.Label, tor.Name)
+				require.NoError(t, client.Delete(tor))
+			}
+		})
+
 		t.Run("with data (stopped)", func(t *testing.T) {
 			b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
 			require.NoError(t, err)
@@ -575,7 +1022,7 @@ func TestRTorrent(t *testing.T) {
 				require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", torrents[0].Hash)
 				require.Equal(t, "Fedora-i3-Live-x86_64-35", torrents[0].Name)
 				require.Equal(t, label.Value, torrents[0].Label)
-				require.Equal(t, 1437206706, torrents[0].Size)
+				require.Equal(t, int64(1437206706), torrents[0].Size)
 
 				t.Run("delete torrent", func(t *testing.T) {
 					err := client.Delete(torrents[0])
@@ -608,6 +1055,34 @@ func TestRTorrent(t *testing.T) {
 		})
 	})
 
+	t.Run("add magnet", func(t *testing.T) {
+		require.NoError(t, client.AddMagnetStopped(bigBuckBunnyMagnet))
+
+		// It will take some time to appear, so retry a few times
+		var torrents []Torrent
+		var err error
+		for i := 0; i <= maxRetries; i++ {
+			<-time.After(time.Second)
+			torrents, err = client.GetTorrents(ViewMain)
+			require.NoError(t, err)
+			if len(torrents) > 0 {
+				break
+			}
+			if i == maxRetries {
+				require.NoError(t, errors.Errorf("magnet did not show up in time"))
+			}
+		}
+		require.Len(t, torrents, 1)
+		require.Equal(t, "DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C", torrents[0].Hash)
+
+		// stopped, so the metadata is never retrieved
+		files, err := client.GetFiles(torrents[0])
+		require.NoError(t, err)
+		require.Empty(t, files)
+
+		require.NoError(t, client.Delete(torrents[0]))
+	})
+
 	t.Run("down total post activity", func(t *testing.T) {
 		total, err := client.DownTotal()
 		require.NoError(t, err)
@@ -621,3 +1096,2910 @@ func TestRTorrent(t *testing.T) {
 	})
 
 }
+
+// fakeHandler answers a single XMLRPC method call on a fake rTorrent server
+type fakeHandler func(args []interface{}) interface{}
+
+// value returns a fakeHandler always answering v
+func value(v interface{}) fakeHandler {
+	return func(args []interface{}) interface{} { return v }
+}
+
+// fakeCaller answers calls with its handlers in process, without any XMLRPC encoding, see NewWithCaller
+// newFakeRTorrent serves the same handlers over XMLRPC.
+type fakeCaller map[string]fakeHandler
+
+func (f fakeCaller) Call(name string, args ...interface{}) (interface{}, error) {
+	if name == "system.multicall" {
+		var results []interface{}
+		for _, c := range args[0].([]interface{}) {
+			c := c.(map[string]interface{})
+			result, err := f.Call(c["methodName"].(string), c["params"].([]interface{})...)
+			if fault, ok := err.(xmlrpc.Fault); ok {
+				results = append(results, map[string]interface{}{"faultCode": fault.Code, "faultString": fault.Message})
+				continue
+			}
+			results = append(results, result)
+		}
+		return []interface{}{results}, nil
+	}
+	handler, ok := f[name]
+	if !ok {
+		return nil, xmlrpc.Fault{Code: -506, Message: "Method '" + name + "' not defined"}
+	}
+	result := handler(args)
+	if fault, ok := result.(xmlrpc.Fault); ok {
+		return nil, fault
+	}
+	return []interface{}{result}, nil
+}
+
+// newFakeRTorrent starts a XMLRPC server answering calls with the given handlers, see fakeCaller
+// Returning an xmlrpc.Fault from a handler produces a fault response.
+func newFakeRTorrent(t *testing.T, handlers map[string]fakeHandler) (*RTorrent, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, args, _, err := xmlrpc.Unmarshal(req.Body)
+		require.NoError(t, err)
+		result, err := fakeCaller(handlers).Call(name, args...)
+		if fault, ok := err.(xmlrpc.Fault); ok {
+			require.NoError(t, xmlrpc.Marshal(w, "", fault))
+			return
+		}
+		require.NoError(t, xmlrpc.Marshal(w, "", result.([]interface{})[0]))
+	}))
+	t.Cleanup(server.Close)
+	return New(server.URL, false), server
+}
+
+// multicallRows answers a d.multicall2 style call from per item values keyed by command
+// Commands missing from an item are answered with 0.
+func multicallRows(items []map[string]interface{}) fakeHandler {
+	return func(args []interface{}) interface{} {
+		rows := []interface{}{}
+		for _, item := range items {
+			var row []interface{}
+			for _, arg := range args[2:] {
+				cmd := arg.(string)
+				value, ok := item[cmd[:len(cmd)-1]]
+				if !ok {
+					value = 0
+				}
+				row = append(row, value)
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+}
+
+func TestGetTorrentsFiltered(t *testing.T) {
+	items := []map[string]interface{}{
+		{"d.hash": "ABC", "d.name": "complete", "d.complete": 1},
+		{"d.hash": "DEF", "d.name": "incomplete", "d.complete": 0},
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows(items),
+		"d.multicall.filtered": func(args []interface{}) interface{} {
+			require.Equal(t, "main", args[1])
+			require.Equal(t, "d.complete=", args[2])
+			// rTorrent evaluates the filter, keep the complete torrents only
+			return multicallRows(items[:1])(append([]interface{}{args[0], args[1]}, args[3:]...))
+		},
+	})
+
+	torrents, err := client.GetTorrentsFiltered(ViewMain, "d.complete=")
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, "ABC", torrents[0].Hash)
+	require.True(t, torrents[0].Completed)
+
+	torrents, err = client.GetTorrentsWhere(ViewMain, func(t Torrent) bool { return t.Completed })
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, "ABC", torrents[0].Hash)
+
+	torrents, err = client.GetTorrentsWhere(ViewMain, func(t Torrent) bool { return false })
+	require.NoError(t, err)
+	require.Empty(t, torrents)
+}
+
+func TestLargeSizes(t *testing.T) {
+	const size, completed = int64(6000000000), int64(5000000000)
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "abc", "d.size_bytes": size, "d.down.total": completed, "d.up.total": 2 * size},
+		}),
+		"d.complete":                 value(0),
+		"d.completed_bytes":          value(completed),
+		"d.down.rate":                value(0),
+		"d.up.rate":                  value(0),
+		"d.ratio":                    value(0),
+		"d.size_bytes":               value(size),
+		"d.completed_chunks":         value(0),
+		"d.size_chunks":              value(0),
+		"d.chunk_size":               value(0),
+		"d.peers_connected":          value(0),
+		"d.peers_complete":           value(0),
+		"d.peers_accounted":          value(0),
+		"f.multicall":                value([]interface{}{[]interface{}{"a.iso", size, 1, 0, 0}}),
+		"throttle.global_down.total": value(completed),
+		"throttle.global_up.total":   value(2 * size),
+	})
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, size, torrents[0].Size)
+	require.Equal(t, completed, torrents[0].Downloaded)
+	require.Equal(t, 2*size, torrents[0].Uploaded)
+
+	status, err := client.GetStatus(torrents[0])
+	require.NoError(t, err)
+	require.Equal(t, size, status.Size)
+	require.Equal(t, completed, status.CompletedBytes)
+
+	files, err := client.GetFiles(torrents[0])
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, size, files[0].Size)
+
+	down, err := client.DownTotal()
+	require.NoError(t, err)
+	require.Equal(t, completed, down)
+	up, err := client.UpTotal()
+	require.NoError(t, err)
+	require.Equal(t, 2*size, up)
+}
+
+func TestCallHelpers(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"string":   value("abc"),
+		"int":      value(42),
+		"int64":    value(int64(5000000000)),
+		"boolean":  value(true),
+		"list":     value([]interface{}{"a", 1}),
+		"fault":    value(xmlrpc.Fault{Code: -501, Message: "Could not find info-hash."}),
+		"with.arg": func(args []interface{}) interface{} { return args[0] },
+	})
+
+	t.Run("string", func(t *testing.T) {
+		s, err := client.callString("string")
+		require.NoError(t, err)
+		require.Equal(t, "abc", s)
+		s, err = client.callString("with.arg", "ABC")
+		require.NoError(t, err)
+		require.Equal(t, "ABC", s)
+		_, err = client.callString("int")
+		require.EqualError(t, err, "int result isn't string: 42")
+	})
+
+	t.Run("int", func(t *testing.T) {
+		i, err := client.callInt("int")
+		require.NoError(t, err)
+		require.Equal(t, 42, i)
+		i64, err := client.callInt64("int64")
+		require.NoError(t, err)
+		require.Equal(t, int64(5000000000), i64)
+		_, err = client.callInt("string")
+		require.EqualError(t, err, "string result isn't int: abc")
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		b, err := client.callBool("boolean")
+		require.NoError(t, err)
+		require.True(t, b)
+		b, err = client.callBool("with.arg", 0)
+		require.NoError(t, err)
+		require.False(t, b)
+		b, err = client.callBool("with.arg", 1)
+		require.NoError(t, err)
+		require.True(t, b)
+		_, err = client.callBool("string")
+		require.EqualError(t, err, "string result isn't bool: abc")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		list, err := client.callList("list")
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"a", 1}, list)
+		_, err = client.callList("string")
+		require.EqualError(t, err, "string result isn't a list: abc")
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		require.NoError(t, client.callDiscard("string"))
+		require.EqualError(t, client.callDiscard("fault"), "fault XMLRPC call failed: -501: Could not find info-hash.")
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		_, err := client.callString("fault")
+		require.EqualError(t, err, "fault XMLRPC call failed: -501: Could not find info-hash.")
+		var fault xmlrpc.Fault
+		require.True(t, errors.As(err, &fault))
+		require.Equal(t, -501, fault.Code)
+	})
+}
+
+func TestVersions(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"system.client_version":  func(args []interface{}) interface{} { return "0.9.8" },
+		"system.library_version": func(args []interface{}) interface{} { return "0.13.8" },
+	})
+	clientVersion, err := client.ClientVersion()
+	require.NoError(t, err)
+	require.Equal(t, "0.9.8", clientVersion)
+	libraryVersion, err := client.LibraryVersion()
+	require.NoError(t, err)
+	require.Equal(t, "0.13.8", libraryVersion)
+
+	versions, err := client.Versions()
+	require.NoError(t, err)
+	require.Equal(t, Versions{Client: "0.9.8", Library: "0.13.8"}, versions)
+}
+
+func TestAddresses(t *testing.T) {
+	addresses := map[string]interface{}{}
+	set := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			addresses[name] = args[1]
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"network.bind_address.set":  set("bind"),
+		"network.local_address.set": set("local"),
+	})
+
+	require.NoError(t, client.SetBindAddress("192.0.2.10"))
+	require.NoError(t, client.SetLocalAddress("seedbox.example.org"))
+	require.NoError(t, client.SetLocalAddress("2001:db8::1"))
+	require.Equal(t, map[string]interface{}{"bind": "192.0.2.10", "local": "2001:db8::1"}, addresses)
+
+	require.Error(t, client.SetBindAddress("seedbox.example.org"), "only an IP can be bound")
+	for _, addr := range []string{"", "seed box", "http://seedbox.example.org"} {
+		require.Error(t, client.SetLocalAddress(addr), addr)
+	}
+	require.Equal(t, map[string]interface{}{"bind": "192.0.2.10", "local": "2001:db8::1"}, addresses)
+}
+
+func TestCapabilities(t *testing.T) {
+	handlers := map[string]fakeHandler{
+		"system.listMethods": func(args []interface{}) interface{} {
+			return []interface{}{"d.multicall2", "load.start", "system.listMethods"}
+		},
+	}
+	client, _ := newFakeRTorrent(t, handlers)
+
+	for name, expected := range map[string]bool{"d.multicall2": true, "load.start": true, "d.multicall": false} {
+		supported, err := client.SupportsMethod(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, supported, name)
+	}
+
+	handlers["system.listMethods"] = func(args []interface{}) interface{} { return "d.multicall2" }
+	_, err := client.SupportsMethod("d.multicall2")
+	require.Error(t, err, "a result that isn't a list is an error, not a panic")
+
+	handlers["system.listMethods"] = func(args []interface{}) interface{} { return []interface{}{} }
+	supported, err := client.SupportsMethod("d.multicall2")
+	require.NoError(t, err)
+	require.False(t, supported)
+
+	version, err := client.APIVersion()
+	require.NoError(t, err, "older builds lack system.api_version")
+	require.Empty(t, version)
+
+	handlers["system.api_version"] = func(args []interface{}) interface{} { return 10 }
+	version, err = client.APIVersion()
+	require.NoError(t, err)
+	require.Equal(t, "10", version)
+
+	handlers["system.api_version"] = func(args []interface{}) interface{} { return "11" }
+	version, err = client.APIVersion()
+	require.NoError(t, err)
+	require.Equal(t, "11", version)
+}
+
+func TestSaveSession(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		saved := false
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"session.path": func(args []interface{}) interface{} { return "/session/" },
+			"session.save": func(args []interface{}) interface{} { saved = true; return 0 },
+		})
+		require.NoError(t, client.SaveSession())
+		require.True(t, saved)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"session.path": func(args []interface{}) interface{} { return "" },
+		})
+		require.Equal(t, ErrSessionNotConfigured, client.SaveSession())
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"session.path": func(args []interface{}) interface{} { return "/session/" },
+			"session.save": func(args []interface{}) interface{} {
+				return xmlrpc.Fault{Code: -503, Message: "Could not save session"}
+			},
+		})
+		err := client.SaveSession()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Could not save session")
+	})
+}
+
+func TestSaveResume(t *testing.T) {
+	t.Run("configured", func(t *testing.T) {
+		var saved []interface{}
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"session.path":        func(args []interface{}) interface{} { return "/session/" },
+			"d.save_full_session": func(args []interface{}) interface{} { saved = args; return 0 },
+		})
+		require.NoError(t, client.SaveResume(Torrent{Hash: "abc"}))
+		require.Equal(t, []interface{}{"abc"}, saved)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"session.path": func(args []interface{}) interface{} { return "" },
+		})
+		require.Equal(t, ErrSessionNotConfigured, client.SaveResume(Torrent{Hash: "abc"}))
+	})
+}
+
+func TestSaveAllSessions(t *testing.T) {
+	var saved []interface{}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"session.path": func(args []interface{}) interface{} { return "/session/" },
+		"d.multicall2": func(args []interface{}) interface{} { saved = args; return []interface{}{[]interface{}{0}} },
+	})
+	require.NoError(t, client.SaveAllSessions())
+	require.Equal(t, []interface{}{"", "main", "d.save_full_session="}, saved)
+}
+
+func TestGetLabels(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.custom1": "tv"},
+			{"d.custom1": "linux"},
+			{"d.custom1": ""},
+			{"d.custom1": "tv"},
+			{"d.custom1": "TV"},
+		}),
+	})
+	labels, err := client.GetLabels()
+	require.NoError(t, err)
+	require.Equal(t, []string{"TV", "linux", "tv"}, labels)
+
+	t.Run("short rows", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"d.multicall2": func(args []interface{}) interface{} {
+				return []interface{}{[]interface{}{"linux"}, []interface{}{}, "not a row"}
+			},
+		})
+		labels, err := client.GetLabels()
+		require.NoError(t, err)
+		require.Equal(t, []string{"linux"}, labels)
+	})
+}
+
+func TestTags(t *testing.T) {
+	label := ""
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.custom1": func(args []interface{}) interface{} { return label },
+		"d.custom1.set": func(args []interface{}) interface{} {
+			label = args[1].(string)
+			return 0
+		},
+	})
+	tor := Torrent{Hash: "abc"}
+
+	t.Run("round trip", func(t *testing.T) {
+		tags := []string{"tv", "my show", "50% off", "a&b+c", "épisode/1", "x;y=z"}
+		require.NoError(t, client.SetTags(tor, tags))
+		require.NotContains(t, label, " ")
+		got, err := client.GetTags(tor)
+		require.NoError(t, err)
+		require.Equal(t, tags, got)
+	})
+
+	t.Run("rutorrent", func(t *testing.T) {
+		for _, label = range []string{"tv,hd,2024", "tv%2Chd%2C2024", "tv,,hd,2024,"} {
+			got, err := client.GetTags(tor)
+			require.NoError(t, err)
+			require.Equal(t, []string{"tv", "hd", "2024"}, got, label)
+		}
+
+		label = "100%"
+		got, err := client.GetTags(tor)
+		require.NoError(t, err)
+		require.Equal(t, []string{"100%"}, got)
+
+		label = ""
+		got, err = client.GetTags(tor)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("delimiter", func(t *testing.T) {
+		require.Error(t, client.SetTags(tor, []string{"a,b"}))
+
+		client.WithTagDelimiter("|")
+		defer client.WithTagDelimiter(DefaultTagDelimiter)
+		require.NoError(t, client.SetTags(tor, []string{"a,b", "c d"}))
+		require.Equal(t, "a%2Cb|c%20d", label)
+		got, err := client.GetTags(tor)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a,b", "c d"}, got)
+	})
+}
+
+func TestGetViews(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"view.list": func(args []interface{}) interface{} {
+			return []interface{}{"main", "default", "name", "active", "started", "stopped", "complete", "incomplete",
+				"hashing", "seeding", "leeching", "label_linux"}
+		},
+	})
+	views, err := client.GetViews()
+	require.NoError(t, err)
+	require.Len(t, views, 12)
+	require.Contains(t, views, "main")
+	require.Contains(t, views, "default")
+	require.Equal(t, "label_linux", views[11])
+}
+
+func TestFieldQuery(t *testing.T) {
+	require.Equal(t, "d.size_bytes=", DSizeInBytes.Query())
+	require.Equal(t, "d.custom1=", DLabel.Query())
+	require.Equal(t, "f.size_bytes=", FSizeInBytes.Query())
+	require.Equal(t, `d.custom1.set="label"`, DLabel.SetValue("label").String())
+}
+
+func TestGetTorrentsFieldMapping(t *testing.T) {
+	item := map[string]interface{}{
+		"d.hash":               "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		"d.name":               "Fedora-i3-Live-x86_64-35",
+		"d.directory":          "/downloads/temp/Fedora-i3-Live-x86_64-35",
+		"d.size_bytes":         1437206706,
+		"d.custom1":            "TestLabel",
+		"d.complete":           1,
+		"d.ratio":              1500,
+		"d.creation_date":      1635781106,
+		"d.timestamp.finished": 1635781300,
+		"d.timestamp.started":  1635781200,
+		"d.up.total":           2048,
+		"d.down.total":         1024,
+		"d.priority":           3,
+		"d.load_date":          1635781150,
+	}
+	expected := Torrent{
+		Hash:       "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		Name:       "Fedora-i3-Live-x86_64-35",
+		Path:       "/downloads/temp/Fedora-i3-Live-x86_64-35",
+		Size:       1437206706,
+		Label:      "TestLabel",
+		Completed:  true,
+		Ratio:      1.5,
+		Created:    time.Unix(1635781106, 0),
+		Finished:   time.Unix(1635781300, 0),
+		Started:    time.Unix(1635781200, 0),
+		Uploaded:   2048,
+		Downloaded: 1024,
+		Priority:   PriorityHigh,
+		AddedAt:    time.Unix(1635781150, 0),
+		FinishedAt: time.Unix(1635781300, 0),
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{item}),
+	})
+
+	t.Run("default order", func(t *testing.T) {
+		torrents, err := client.GetTorrents(ViewMain)
+		require.NoError(t, err)
+		require.Equal(t, []Torrent{expected}, torrents)
+	})
+
+	t.Run("reordered fields", func(t *testing.T) {
+		reversed := make([]torrentField, len(torrentFields))
+		for i, f := range torrentFields {
+			reversed[len(torrentFields)-1-i] = f
+		}
+		torrents, err := client.getTorrents(context.Background(), ViewMain, reversed)
+		require.NoError(t, err)
+		require.Equal(t, []Torrent{expected}, torrents)
+	})
+
+	t.Run("missing columns", func(t *testing.T) {
+		torrents, err := client.getTorrents(context.Background(), ViewMain, torrentFields[:2])
+		require.NoError(t, err)
+		require.Equal(t, []Torrent{{Hash: expected.Hash, Name: expected.Name}}, torrents)
+	})
+}
+
+func TestGetTorrentsWithFields(t *testing.T) {
+	var columns []interface{}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": func(args []interface{}) interface{} {
+			columns = args[2:]
+			return multicallRows([]map[string]interface{}{
+				{"d.hash": "ABC", "d.name": "a", "d.up.total": int64(6000000000), "d.down.total": 1024, "d.state": 1},
+			})(args)
+		},
+	})
+
+	torrents, err := client.GetTorrentsWithFields(ViewMain, DUpTotal, DDownTotal)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"d.hash=", "d.up.total=", "d.down.total="}, columns)
+	require.Equal(t, []Torrent{{Hash: "ABC", Uploaded: 6000000000, Downloaded: 1024}}, torrents)
+
+	torrents, err = client.GetTorrentsWithFields(ViewMain, DState)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"d.hash=", "d.complete=", "d.state=", "d.is_active=", "d.hashing="}, columns)
+	require.Equal(t, StateStarted, torrents[0].State)
+
+	_, err = client.GetTorrentsWithFields(ViewMain, DBasePath)
+	require.Error(t, err)
+
+	// the first unsupported field in argument order is reported, every time
+	for i := 0; i < 20; i++ {
+		_, err = client.GetTorrentsWithFields(ViewMain, DName, DSessionFile, DBasePath, DDirectory, DThrottleName)
+		require.EqualError(t, err, "unsupported torrent field: d.session_file")
+	}
+}
+
+func TestTorrentMessage(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "HEALTHY", "d.message": ""},
+			{"d.hash": "STUCK", "d.message": "Tracker: [Failure reason \"torrent not registered\"]"},
+		}),
+	})
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+	require.Empty(t, torrents[0].Message)
+	require.NotContains(t, torrents[0].Pretty(), "Message")
+	require.Equal(t, "Tracker: [Failure reason \"torrent not registered\"]", torrents[1].Message)
+	require.Contains(t, torrents[1].Pretty(), "\tMessage: Tracker: [Failure reason \"torrent not registered\"]\n")
+}
+
+func TestTorrentState(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "STOPPED", "d.state": 0, "d.is_active": 0, "d.complete": 1},
+			{"d.hash": "PAUSED", "d.state": 1, "d.is_active": 0, "d.complete": 1},
+			{"d.hash": "DOWNLOADING", "d.state": 1, "d.is_active": 1},
+			{"d.hash": "SEEDING", "d.state": 1, "d.is_active": 1, "d.complete": 1},
+			{"d.hash": "HASHING", "d.state": 1, "d.is_active": 1, "d.hashing": 1},
+			{"d.hash": "HASHING_STOPPED", "d.state": 0, "d.hashing": 3},
+		}),
+	})
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	states := map[string]TorrentState{}
+	for _, torrent := range torrents {
+		states[torrent.Hash] = torrent.State
+	}
+	require.Equal(t, map[string]TorrentState{
+		"STOPPED":         StateStopped,
+		"PAUSED":          StateStarted,
+		"DOWNLOADING":     StateStarted,
+		"SEEDING":         StateSeeding,
+		"HASHING":         StateHashing,
+		"HASHING_STOPPED": StateHashing,
+	}, states)
+
+	require.Equal(t, "stopped", StateStopped.String())
+	require.Equal(t, "seeding", StateSeeding.String())
+	require.Equal(t, "unknown (9)", TorrentState(9).String())
+}
+
+func TestGetTorrentsLite(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "299939CFF841ED7FFCA2B3C2A35711C12589632B", "d.name": "Fedora-i3-Live-x86_64-35", "d.size_bytes": 1437206706},
+			{"d.hash": "0000000000000000000000000000000000000000", "d.name": "empty", "d.size_bytes": 0},
+		}),
+	})
+	torrents, err := client.GetTorrentsLite(ViewMain)
+	require.NoError(t, err)
+	require.Equal(t, []TorrentLite{
+		{Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B", Name: "Fedora-i3-Live-x86_64-35", Size: 1437206706},
+		{Hash: "0000000000000000000000000000000000000000", Name: "empty", Size: 0},
+	}, torrents)
+}
+
+func TestMalformedRows(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": func(args []interface{}) interface{} {
+			return []interface{}{
+				[]interface{}{"299939CFF841ED7FFCA2B3C2A35711C12589632B", "Fedora-i3-Live-x86_64-35", 1437206706},
+				[]interface{}{"0000000000000000000000000000000000000000"},
+				"not a row",
+			}
+		},
+		"f.multicall": func(args []interface{}) interface{} {
+			return []interface{}{[]interface{}{"a.iso", 1024, 1, 4, 4}, []interface{}{1024}, []interface{}{}}
+		},
+		"view.list": func(args []interface{}) interface{} { return "main" },
+		"t.multicall": func(args []interface{}) interface{} {
+			return []interface{}{
+				[]interface{}{"http://tracker.example/announce", 1, 1, 12, 3, 1800, 0},
+				[]interface{}{"http://short.example/announce", 1, 1, 12, 3, 1800},
+				// an older row with the URL only, without the type, enabled and scrape columns
+				[]interface{}{"http://url-only.example/announce"},
+				[]interface{}{"http://type-only.example/announce", 2},
+				"not a row",
+			}
+		},
+		"p.multicall": func(args []interface{}) interface{} {
+			return []interface{}{
+				[]interface{}{"10.0.0.1", 6881, "rTorrent 0.9.8", 1024, 512, 42, 1},
+				[]interface{}{"10.0.0.2", 6881},
+				"not a row",
+			}
+		},
+	})
+
+	torrents, err := client.GetTorrentsLite(ViewMain)
+	require.NoError(t, err)
+	require.Equal(t, []TorrentLite{{Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B", Name: "Fedora-i3-Live-x86_64-35", Size: 1437206706}}, torrents)
+
+	files, err := client.GetFiles(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, []File{{Path: "a.iso", Size: 1024, Priority: 1, CompletedChunks: 4, SizeChunks: 4}}, files)
+
+	views, err := client.GetViews()
+	require.NoError(t, err)
+	require.Empty(t, views)
+
+	trackers, err := client.GetTrackers(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, []Tracker{{URL: "http://tracker.example/announce", Type: TrackerHTTP, Enabled: true,
+		ScrapeComplete: 12, ScrapeIncomplete: 3, MinInterval: 30 * time.Minute}}, trackers)
+
+	peers, err := client.GetPeers(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, []Peer{{Address: "10.0.0.1", Port: 6881, ClientVersion: "rTorrent 0.9.8", DownRate: 1024, UpRate: 512,
+		CompletedPercent: 42, Encrypted: true}}, peers)
+}
+
+func TestDetailedState(t *testing.T) {
+	for _, tc := range []struct {
+		name                                   string
+		open, state, active, hashing, complete int
+		message                                string
+		expected                               DetailedState
+	}{
+		{"closed", 0, 0, 0, 0, 0, "", DetailedStateStopped},
+		{"stopped while open", 1, 0, 0, 0, 0, "", DetailedStateStopped},
+		{"queued", 1, 1, 0, 0, 0, "", DetailedStateQueued},
+		{"checking", 1, 1, 1, 1, 0, "", DetailedStateChecking},
+		{"downloading", 1, 1, 1, 0, 0, "", DetailedStateDownloading},
+		{"seeding", 1, 1, 1, 0, 1, "", DetailedStateSeeding},
+		{"errored", 1, 1, 1, 0, 0, "Tracker: [Failure reason \"unregistered torrent\"]", DetailedStateErrored},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+				"d.is_open":   func(args []interface{}) interface{} { return tc.open },
+				"d.state":     func(args []interface{}) interface{} { return tc.state },
+				"d.is_active": func(args []interface{}) interface{} { return tc.active },
+				"d.hashing":   func(args []interface{}) interface{} { return tc.hashing },
+				"d.complete":  func(args []interface{}) interface{} { return tc.complete },
+				"d.message":   func(args []interface{}) interface{} { return tc.message },
+			})
+			state, err := client.DetailedState(Torrent{Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B"})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, state)
+		})
+	}
+
+	t.Run("fault", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{})
+		_, err := client.DetailedState(Torrent{Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "d.is_open")
+	})
+}
+
+func TestExport(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+	require.NoError(t, err)
+	hash := "299939CFF841ED7FFCA2B3C2A35711C12589632B"
+
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": hash, "d.session_file": "/session/" + hash + ".torrent"},
+		}),
+		"execute.capture": func(args []interface{}) interface{} {
+			// base64 wraps its output, which must be tolerated
+			encoded := base64.StdEncoding.EncodeToString(b)
+			encoded = encoded[:76] + "\n" + encoded[76:]
+			if args[1] == "sh" {
+				require.Equal(t, []interface{}{"base64", "/session/" + hash + ".torrent"}, args[5:])
+				return encoded + "\n0"
+			}
+			require.Equal(t, []interface{}{"", "base64", "/session/" + hash + ".torrent"}, args)
+			return encoded + "\n"
+		},
+	})
+
+	t.Run("all", func(t *testing.T) {
+		exported, err := client.ExportAll(ViewMain)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{hash: b}, exported)
+	})
+
+	t.Run("each", func(t *testing.T) {
+		exported := map[string][]byte{}
+		err := client.ExportEach(ViewMain, func(hash string, data []byte) error {
+			exported[hash] = data
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{hash: b}, exported)
+	})
+
+	t.Run("short row", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"d.multicall2": func(args []interface{}) interface{} {
+				return []interface{}{[]interface{}{hash, "/session/" + hash + ".torrent"}, []interface{}{"BBBB"}}
+			},
+		})
+		_, err := client.ExportAll(ViewMain)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "row 1: [BBBB]")
+		err = client.ExportEach(ViewMain, func(hash string, data []byte) error { return nil })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "row 1")
+	})
+}
+
+func TestDirectories(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"directory.default": func(args []interface{}) interface{} { return "/downloads" },
+		"session.path":      func(args []interface{}) interface{} { return "/session/" },
+	})
+	session, err := client.SessionDirectory()
+	require.NoError(t, err)
+	require.Equal(t, "/session/", session)
+	downloads, err := client.DefaultDownloadDirectory()
+	require.NoError(t, err)
+	require.Equal(t, "/downloads", downloads)
+}
+
+func TestFreeDiskSpace(t *testing.T) {
+	defaultDir, output, dir := "/downloads", "", ""
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"directory.default": func(args []interface{}) interface{} { return defaultDir },
+		"session.path":      func(args []interface{}) interface{} { return "/session/" },
+		"execute.capture": func(args []interface{}) interface{} {
+			require.Equal(t, []interface{}{"df", "-Pk", "--"}, args[5:8])
+			dir = args[8].(string)
+			return fmt.Sprintf("Filesystem 1024-blocks Used Available Capacity Mounted on\n"+
+				"/dev/sda1 %s /\n0", output)
+		},
+	})
+
+	output = "10485760 2097152 8388608 20%"
+	free, err := client.FreeDiskSpace()
+	require.NoError(t, err)
+	require.Equal(t, int64(8388608)*1024, free)
+	require.Equal(t, "/downloads", dir)
+
+	// above 2^32 KiB, i.e. 4 TiB
+	output = "10000000000 1000000000 9000000000 10%"
+	free, err = client.FreeDiskSpace()
+	require.NoError(t, err)
+	require.Equal(t, int64(9000000000)*1024, free)
+
+	defaultDir = ""
+	_, err = client.FreeDiskSpace()
+	require.NoError(t, err)
+	require.Equal(t, "/session/", dir, "the session directory is used instead")
+
+	output = "garbage"
+	_, err = client.FreeDiskSpace()
+	require.Error(t, err)
+}
+
+func TestExecuteCapture(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"execute.capture": func(args []interface{}) interface{} {
+			require.Equal(t, []interface{}{"", "sh", "-c", executeCaptureScript, "sh"}, args[:5])
+			switch args[5] {
+			case "echo":
+				return "hello world\n0"
+			case "false":
+				return "some output\n1"
+			}
+			return "garbage"
+		},
+	})
+
+	t.Run("success", func(t *testing.T) {
+		output, err := client.ExecuteCapture("echo", "hello world")
+		require.NoError(t, err)
+		require.Equal(t, "hello world", output)
+	})
+
+	t.Run("non-zero exit", func(t *testing.T) {
+		output, err := client.ExecuteCapture("false")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exited with code 1")
+		require.Contains(t, err.Error(), "some output")
+		require.Equal(t, "some output", output)
+	})
+
+	t.Run("unexpected output", func(t *testing.T) {
+		_, err := client.ExecuteCapture("other")
+		require.Error(t, err)
+	})
+}
+
+func TestActiveCounts(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"view.size": func(args []interface{}) interface{} {
+			return map[string]int{"started": 5, "seeding": 3}[args[1].(string)]
+		},
+	})
+	downloading, seeding, err := client.ActiveCounts()
+	require.NoError(t, err)
+	require.Equal(t, 2, downloading)
+	require.Equal(t, 3, seeding)
+}
+
+func TestReAddWithData(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+	require.NoError(t, err)
+
+	for _, skipHashCheck := range []bool{false, true} {
+		var loaded, checked []interface{}
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"load.raw": func(args []interface{}) interface{} {
+				loaded = args
+				return 0
+			},
+			"d.check_hash": func(args []interface{}) interface{} {
+				checked = args
+				return 0
+			},
+		})
+		err := client.ReAddWithData(b, "/downloads/complete", skipHashCheck)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"", []interface{}{b, `d.directory.set="/downloads/complete"`}}, loaded)
+		if skipHashCheck {
+			require.Nil(t, checked)
+		} else {
+			require.Equal(t, []interface{}{"299939CFF841ED7FFCA2B3C2A35711C12589632B"}, checked)
+		}
+	}
+}
+
+func TestAddTooLarge(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+	require.NoError(t, err)
+
+	// Simulate rTorrent dropping the connection instead of answering
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, _, _, err := xmlrpc.Unmarshal(req.Body)
+		require.NoError(t, err)
+		if name == "load.raw_start" {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		require.Equal(t, "network.xmlrpc.size_limit", name)
+		require.NoError(t, xmlrpc.Marshal(w, "", 65536))
+	}))
+	defer server.Close()
+	client := New(server.URL, false)
+
+	err = client.AddTorrent(b)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrRequestTooLarge))
+	require.Contains(t, err.Error(), "load.normal")
+
+	err = client.AddTorrent(b[:1024])
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrRequestTooLarge))
+}
+
+func TestGetStatus(t *testing.T) {
+	values := map[string]interface{}{
+		"d.complete":         0,
+		"d.completed_bytes":  524288,
+		"d.down.rate":        1024,
+		"d.up.rate":          512,
+		"d.ratio":            250,
+		"d.size_bytes":       1437206706,
+		"d.completed_chunks": 2,
+		"d.size_chunks":      5483,
+		"d.chunk_size":       262144,
+		"d.peers_connected":  12,
+		"d.peers_complete":   8,
+		"d.peers_accounted":  4,
+	}
+	handlers := map[string]fakeHandler{}
+	for cmd, value := range values {
+		value := value
+		handlers[cmd] = func(args []interface{}) interface{} {
+			require.Equal(t, []interface{}{"299939CFF841ED7FFCA2B3C2A35711C12589632B"}, args)
+			return value
+		}
+	}
+	client, _ := newFakeRTorrent(t, handlers)
+
+	status, err := client.GetStatus(Torrent{Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B"})
+	require.NoError(t, err)
+	require.Equal(t, Status{
+		Completed:         false,
+		CompletedBytes:    524288,
+		DownRate:          1024,
+		UpRate:            512,
+		Ratio:             0.25,
+		Size:              1437206706,
+		CompletedChunks:   2,
+		SizeChunks:        5483,
+		ChunkSize:         262144,
+		PeersConnected:    12,
+		SeedersConnected:  8,
+		LeechersConnected: 4,
+	}, status)
+}
+
+func TestGetAllTorrentsWithViews(t *testing.T) {
+	views := map[string][]map[string]interface{}{
+		"main":    {{"d.hash": "AAAA", "d.name": "a"}, {"d.hash": "BBBB", "d.name": "b"}},
+		"started": {{"d.hash": "AAAA"}},
+		"stopped": {{"d.hash": "BBBB"}},
+		"tv":      {{"d.hash": "AAAA"}, {"d.hash": "BBBB"}},
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"view.list": func(args []interface{}) interface{} {
+			return []interface{}{"main", "started", "stopped", "tv"}
+		},
+		"d.multicall2": func(args []interface{}) interface{} {
+			return multicallRows(views[args[1].(string)])(args)
+		},
+	})
+
+	torrents, err := client.GetAllTorrentsWithViews()
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+	require.Equal(t, "AAAA", torrents[0].Hash)
+	require.Equal(t, "a", torrents[0].Name)
+	require.Equal(t, []string{"main", "started", "tv"}, torrents[0].Views)
+	require.Equal(t, "BBBB", torrents[1].Hash)
+	require.Equal(t, []string{"main", "stopped", "tv"}, torrents[1].Views)
+
+	t.Run("malformed view", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"view.list": func(args []interface{}) interface{} {
+				return []interface{}{"main", "started", "broken"}
+			},
+			"d.multicall2": func(args []interface{}) interface{} {
+				switch args[1].(string) {
+				case "started":
+					return []interface{}{[]interface{}{}, "not a row", []interface{}{"AAAA"}}
+				case "broken":
+					return "not a list"
+				}
+				return multicallRows(views["main"])(args)
+			},
+		})
+		torrents, err := client.GetAllTorrentsWithViews()
+		require.NoError(t, err)
+		require.Len(t, torrents, 2)
+		require.Equal(t, []string{"main", "started"}, torrents[0].Views)
+		require.Equal(t, []string{"main"}, torrents[1].Views)
+	})
+
+	t.Run("view fault", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"view.list": func(args []interface{}) interface{} { return []interface{}{"main", "gone"} },
+			"d.multicall2": func(args []interface{}) interface{} {
+				if args[1].(string) == "gone" {
+					return xmlrpc.Fault{Code: -500, Message: "Could not find view: gone"}
+				}
+				return multicallRows(views["main"])(args)
+			},
+		})
+		_, err := client.GetAllTorrentsWithViews()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Could not find view")
+	})
+}
+
+func TestRateBetween(t *testing.T) {
+	start := time.Unix(1635781106, 0)
+	for _, tc := range []struct {
+		name           string
+		prev, curr     StatsSnapshot
+		expectedDown   float64
+		expectedUpRate float64
+	}{
+		{"normal", StatsSnapshot{start, 1000, 500}, StatsSnapshot{start.Add(10 * time.Second), 11000, 1500}, 1000, 100},
+		{"sub second", StatsSnapshot{start, 0, 0}, StatsSnapshot{start.Add(500 * time.Millisecond), 512, 256}, 1024, 512},
+		{"idle", StatsSnapshot{start, 1000, 500}, StatsSnapshot{start.Add(time.Minute), 1000, 500}, 0, 0},
+		{"zero elapsed", StatsSnapshot{start, 1000, 500}, StatsSnapshot{start, 2000, 1000}, 0, 0},
+		{"swapped", StatsSnapshot{start.Add(time.Second), 2000, 1000}, StatsSnapshot{start, 1000, 500}, 0, 0},
+		{"restarted", StatsSnapshot{start, 5000, 5000}, StatsSnapshot{start.Add(time.Second), 100, 6000}, 0, 1000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			down, up := RateBetween(tc.prev, tc.curr)
+			require.Equal(t, tc.expectedDown, down)
+			require.Equal(t, tc.expectedUpRate, up)
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"throttle.global_down.total": func(args []interface{}) interface{} { return 2048 },
+		"throttle.global_up.total":   func(args []interface{}) interface{} { return 1024 },
+	})
+	before := time.Now()
+	snapshot, err := client.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, int64(2048), snapshot.DownTotal)
+	require.Equal(t, int64(1024), snapshot.UpTotal)
+	require.False(t, snapshot.Time.Before(before))
+}
+
+func TestTorrentRateLimits(t *testing.T) {
+	limits := map[string]int{}
+	throttle := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			kib, err := strconv.Atoi(args[2].(string))
+			require.NoError(t, err)
+			limits[dir+"/"+args[1].(string)] = kib * 1024
+			return 0
+		}
+	}
+	max := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			if limit, ok := limits[dir+"/"+args[1].(string)]; ok {
+				return limit
+			}
+			return -1
+		}
+	}
+	groups := map[string]string{"SEEDING": ThrottleGroupSeed}
+	states := map[string]int{"SEEDING": 1}
+	var calls []string
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"throttle.up":       throttle("up"),
+		"throttle.down":     throttle("down"),
+		"throttle.up.max":   max("up"),
+		"throttle.down.max": max("down"),
+		"d.throttle_name": func(args []interface{}) interface{} {
+			return groups[args[0].(string)]
+		},
+		"d.throttle_name.set": func(args []interface{}) interface{} {
+			hash := args[0].(string)
+			if states[hash] == 1 {
+				return xmlrpc.Fault{Code: -503, Message: "Cannot set throttle name on an active download."}
+			}
+			calls = append(calls, "d.throttle_name.set "+hash)
+			groups[hash] = args[1].(string)
+			return 0
+		},
+		"d.state": func(args []interface{}) interface{} {
+			return states[args[0].(string)]
+		},
+		"d.stop": func(args []interface{}) interface{} {
+			calls = append(calls, "d.stop "+args[0].(string))
+			states[args[0].(string)] = 0
+			return 0
+		},
+		"d.start": func(args []interface{}) interface{} {
+			calls = append(calls, "d.start "+args[0].(string))
+			states[args[0].(string)] = 1
+			return 0
+		},
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		rate, err := client.DownloadRate(Torrent{Hash: "STOPPED"})
+		require.NoError(t, err)
+		require.Zero(t, rate)
+		group, err := client.ThrottleGroup(Torrent{Hash: "STOPPED"})
+		require.NoError(t, err)
+		require.Empty(t, group)
+	})
+
+	t.Run("stopped torrent", func(t *testing.T) {
+		tor := Torrent{Hash: "STOPPED"}
+		require.NoError(t, client.SetDownloadRate(tor, 100*1024))
+		require.NoError(t, client.SetUploadRate(tor, 50*1024+1))
+		require.Equal(t, []string{"d.throttle_name.set STOPPED"}, calls)
+
+		down, err := client.DownloadRate(tor)
+		require.NoError(t, err)
+		require.Equal(t, 100*1024, down)
+		up, err := client.UploadRate(tor)
+		require.NoError(t, err)
+		require.Equal(t, 51*1024, up)
+		group, err := client.ThrottleGroup(tor)
+		require.NoError(t, err)
+		require.Equal(t, "torrent_stopped", group)
+	})
+
+	t.Run("started torrent in a group", func(t *testing.T) {
+		calls = nil
+		tor := Torrent{Hash: "SEEDING"}
+		require.NoError(t, client.SetUploadRate(tor, 10*1024))
+		require.Equal(t, []string{"d.stop SEEDING", "d.throttle_name.set SEEDING", "d.start SEEDING"}, calls)
+		up, err := client.UploadRate(tor)
+		require.NoError(t, err)
+		require.Equal(t, 10*1024, up)
+		require.Equal(t, 1, states["SEEDING"])
+	})
+
+	t.Run("remove group", func(t *testing.T) {
+		tor := Torrent{Hash: "STOPPED"}
+		require.NoError(t, client.SetThrottleGroup(tor, ""))
+		down, err := client.DownloadRate(tor)
+		require.NoError(t, err)
+		require.Zero(t, down)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.Error(t, client.SetDownloadRate(Torrent{Hash: "STOPPED"}, -1))
+	})
+}
+
+func TestGroupRateLimits(t *testing.T) {
+	limits := map[string]int{}
+	throttle := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			kib, err := strconv.Atoi(args[2].(string))
+			require.NoError(t, err)
+			limits[dir+"/"+args[1].(string)] = kib * 1024
+			return 0
+		}
+	}
+	max := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			if limit, ok := limits[dir+"/"+args[1].(string)]; ok {
+				return limit
+			}
+			return -1
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"throttle.up":       throttle("up"),
+		"throttle.down":     throttle("down"),
+		"throttle.up.max":   max("up"),
+		"throttle.down.max": max("down"),
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		seeding, err := client.SeedingRateLimit()
+		require.NoError(t, err)
+		require.Zero(t, seeding)
+	})
+
+	t.Run("set and read", func(t *testing.T) {
+		require.NoError(t, client.SetSeedingRateLimit(512*1024))
+		require.NoError(t, client.SetLeechingRateLimit(2048*1024))
+		require.Equal(t, map[string]int{"up/seed": 512 * 1024, "down/leech": 2048 * 1024}, limits)
+
+		seeding, err := client.SeedingRateLimit()
+		require.NoError(t, err)
+		require.Equal(t, 512*1024, seeding)
+		leeching, err := client.LeechingRateLimit()
+		require.NoError(t, err)
+		require.Equal(t, 2048*1024, leeching)
+	})
+
+	t.Run("rounded up to KiB", func(t *testing.T) {
+		require.NoError(t, client.SetSeedingRateLimit(1))
+		seeding, err := client.SeedingRateLimit()
+		require.NoError(t, err)
+		require.Equal(t, 1024, seeding)
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		require.Error(t, client.SetLeechingRateLimit(-1))
+	})
+}
+
+func TestInspect(t *testing.T) {
+	handlers := map[string]fakeHandler{}
+	for _, field := range InspectFields {
+		cmd := field.Cmd()
+		handlers[cmd] = func(args []interface{}) interface{} {
+			require.Equal(t, []interface{}{"abc"}, args)
+			return cmd
+		}
+	}
+	client, _ := newFakeRTorrent(t, handlers)
+
+	values, err := client.Inspect(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Len(t, values, len(InspectFields))
+	require.Equal(t, "d.name", values["d.name"])
+	require.Equal(t, "d.throttle_name", values["d.throttle_name"])
+
+	t.Run("custom fields", func(t *testing.T) {
+		defaultFields := InspectFields
+		defer func() { InspectFields = defaultFields }()
+
+		InspectFields = []Field{DName, DRatio}
+		values, err := client.Inspect(Torrent{Hash: "abc"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"d.name": "d.name", "d.ratio": "d.ratio"}, values)
+
+		InspectFields = []Field{DName, "d.unknown"}
+		_, err = client.Inspect(Torrent{Hash: "abc"})
+		require.Error(t, err)
+	})
+}
+
+func TestMultiLineLabel(t *testing.T) {
+	note := "  first line\r\nsecond line\n\n"
+	labels := map[string]interface{}{}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.custom1.set": func(args []interface{}) interface{} {
+			labels[args[0].(string)] = args[1]
+			return 0
+		},
+		"d.multicall2": func(args []interface{}) interface{} {
+			return multicallRows([]map[string]interface{}{{"d.hash": "abc", "d.custom1": labels["abc"]}})(args)
+		},
+	})
+
+	require.NoError(t, client.SetLabel(Torrent{Hash: "abc"}, note))
+	require.Equal(t, note, labels["abc"])
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, note, torrents[0].Label)
+}
+
+func TestGlobalRateLimits(t *testing.T) {
+	rates := map[string]int{"down": 0, "up": 0}
+	get := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} { return rates[dir] }
+	}
+	set := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			rates[dir] = args[1].(int)
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"throttle.global_down.max_rate":     get("down"),
+		"throttle.global_up.max_rate":       get("up"),
+		"throttle.global_down.max_rate.set": set("down"),
+		"throttle.global_up.max_rate.set":   set("up"),
+	})
+
+	down, err := client.GlobalDownMaxRate()
+	require.NoError(t, err)
+	require.Zero(t, down)
+
+	require.NoError(t, client.SetGlobalDownRate(1024*1024))
+	require.NoError(t, client.SetGlobalUpRate(256*1024))
+	down, err = client.GlobalDownMaxRate()
+	require.NoError(t, err)
+	require.Equal(t, 1024*1024, down)
+	up, err := client.GlobalUpMaxRate()
+	require.NoError(t, err)
+	require.Equal(t, 256*1024, up)
+
+	require.Error(t, client.SetGlobalUpRate(-1))
+	require.Equal(t, 256*1024, rates["up"])
+}
+
+func TestPauseResume(t *testing.T) {
+	rates := map[string]int{"down": 1024, "up": 0}
+	fail := false
+	get := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} { return rates[dir] }
+	}
+	set := func(dir string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			if fail {
+				return xmlrpc.Fault{Code: -503, Message: "failed"}
+			}
+			rates[dir] = args[1].(int)
+			return 0
+		}
+	}
+	handlers := map[string]fakeHandler{
+		"throttle.global_down.max_rate":     get("down"),
+		"throttle.global_up.max_rate":       get("up"),
+		"throttle.global_down.max_rate.set": set("down"),
+		"throttle.global_up.max_rate.set":   set("up"),
+	}
+	fakeVariables(handlers)
+	client, _ := newFakeRTorrent(t, handlers)
+
+	t.Run("resume without pause", func(t *testing.T) {
+		require.Equal(t, ErrNotPaused, client.Resume())
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		require.NoError(t, client.Pause())
+		require.Equal(t, map[string]int{"down": 1, "up": 1}, rates)
+
+		// pausing again must not overwrite the stored limits
+		require.NoError(t, client.Pause())
+
+		require.NoError(t, client.Resume())
+		require.Equal(t, map[string]int{"down": 1024, "up": 0}, rates)
+		require.Equal(t, ErrNotPaused, client.Resume())
+	})
+
+	t.Run("failed resume can be retried", func(t *testing.T) {
+		require.NoError(t, client.Pause())
+		fail = true
+		require.Error(t, client.Resume())
+		fail = false
+		require.NoError(t, client.Resume())
+		require.Equal(t, map[string]int{"down": 1024, "up": 0}, rates)
+	})
+
+	t.Run("resume from another client", func(t *testing.T) {
+		require.NoError(t, client.Pause())
+		other, _ := newFakeRTorrent(t, handlers)
+		require.NoError(t, other.Pause(), "already paused")
+		require.NoError(t, other.Resume())
+		require.Equal(t, map[string]int{"down": 1024, "up": 0}, rates)
+		require.Equal(t, ErrNotPaused, client.Resume())
+	})
+
+	t.Run("limit set while paused is kept", func(t *testing.T) {
+		require.NoError(t, client.Pause())
+		require.NoError(t, client.SetGlobalUpRate(2048))
+		require.NoError(t, client.Resume())
+		require.Equal(t, map[string]int{"down": 1024, "up": 2048}, rates)
+		rates["up"] = 0
+	})
+
+	t.Run("failed pause is forgotten", func(t *testing.T) {
+		fail = true
+		require.Error(t, client.Pause())
+		fail = false
+		require.Equal(t, ErrNotPaused, client.Resume())
+		require.Equal(t, map[string]int{"down": 1024, "up": 0}, rates)
+	})
+}
+
+func TestGetHashingTorrents(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": func(args []interface{}) interface{} {
+			require.Equal(t, "hashing", args[1])
+			return multicallRows([]map[string]interface{}{
+				{"d.hash": "abc", "d.name": "halfway", "d.chunks_hashed": 50, "d.size_chunks": 100},
+				{"d.hash": "def", "d.name": "queued", "d.chunks_hashed": 0, "d.size_chunks": 10},
+				{"d.hash": "ghi", "d.name": "empty", "d.chunks_hashed": 0, "d.size_chunks": 0},
+			})(args)
+		},
+	})
+
+	torrents, err := client.GetHashingTorrents()
+	require.NoError(t, err)
+	require.Len(t, torrents, 3)
+	require.Equal(t, "halfway", torrents[0].Name)
+	require.Equal(t, 0.5, torrents[0].HashingProgress)
+	require.Equal(t, "def", torrents[1].Hash)
+	require.Zero(t, torrents[1].HashingProgress)
+	require.Zero(t, torrents[2].HashingProgress)
+}
+
+func TestSetDirectory(t *testing.T) {
+	directory, open := "/downloads/a", 1
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.directory": func(args []interface{}) interface{} { return directory },
+		"d.directory.set": func(args []interface{}) interface{} {
+			if open == 1 {
+				return xmlrpc.Fault{Code: -503, Message: "Cannot change the directory of an open download."}
+			}
+			directory = args[1].(string)
+			return 0
+		},
+		"d.close": func(args []interface{}) interface{} {
+			open = 0
+			return 0
+		},
+		"d.stop": func(args []interface{}) interface{} { return 0 },
+	})
+	tor := Torrent{Hash: "abc"}
+
+	require.Error(t, client.SetDirectory(tor, "/downloads/b"))
+	require.NoError(t, client.StopTorrent(tor))
+	require.NoError(t, client.CloseTorrent(tor))
+	require.NoError(t, client.SetDirectory(tor, "/downloads/b"))
+	got, err := client.GetDirectory(tor)
+	require.NoError(t, err)
+	require.Equal(t, "/downloads/b", got)
+}
+
+func TestMoveDataPhysical(t *testing.T) {
+	var calls []string
+	var mv, dir []interface{}
+	mvFault, mvBackFault, setFault, closeFault := false, false, false, false
+	setDirectory := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, name)
+			dir = args
+			if setFault {
+				return xmlrpc.Fault{Code: -501, Message: "Could not find info-hash."}
+			}
+			return 0
+		}
+	}
+	newClient := func(t *testing.T, multiFile, open, state int) *RTorrent {
+		calls, mv, dir, mvFault, mvBackFault, setFault, closeFault = nil, nil, nil, false, false, false, false
+		record := func(name string, result interface{}) fakeHandler {
+			return func(args []interface{}) interface{} {
+				calls = append(calls, name)
+				return result
+			}
+		}
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"directory.default": record("directory.default", "/downloads/"),
+			"d.directory":       record("d.directory", "/downloads/incoming"),
+			"d.name":            record("d.name", "file.iso"),
+			"d.is_multi_file":   record("d.is_multi_file", multiFile),
+			"d.is_open":         record("d.is_open", open),
+			"d.state":           record("d.state", state),
+			"d.stop":            record("d.stop", 0),
+			"d.open":            record("d.open", 0),
+			"d.start":           record("d.start", 0),
+			"d.check_hash":      record("d.check_hash", 0),
+			"d.close": func(args []interface{}) interface{} {
+				calls = append(calls, "d.close")
+				if closeFault {
+					return xmlrpc.Fault{Code: -501, Message: "Could not find info-hash."}
+				}
+				return 0
+			},
+			"d.directory.set":      setDirectory("d.directory.set"),
+			"d.directory_base.set": setDirectory("d.directory_base.set"),
+			"execute.throw": func(args []interface{}) interface{} {
+				calls = append(calls, "execute.throw")
+				mv = args
+				if mvFault || (mvBackFault && strings.HasPrefix(args[3].(string), "/downloads/done/")) {
+					return xmlrpc.Fault{Code: -503, Message: "Bad return code."}
+				}
+				return 0
+			},
+		})
+		return client
+	}
+	queries := []string{"directory.default", "d.directory", "d.name", "d.is_multi_file", "d.is_open", "d.state"}
+
+	t.Run("started single file", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		require.NoError(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done/"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory.set", "d.start"), calls)
+		require.Equal(t, []interface{}{"", "mv", "--", "/downloads/incoming/file.iso", "/downloads/done/"}, mv)
+		require.Equal(t, []interface{}{"abc", "/downloads/done"}, dir)
+	})
+
+	t.Run("stopped multi file", func(t *testing.T) {
+		// the data directory "incoming" is not named after the torrent, "file.iso"
+		client := newClient(t, 1, 0, 0)
+		require.NoError(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory_base.set"), calls)
+		require.Equal(t, []interface{}{"", "mv", "--", "/downloads/incoming", "/downloads/done/"}, mv)
+		require.Equal(t, []interface{}{"abc", "/downloads/done/incoming"}, dir)
+	})
+
+	t.Run("checked", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		require.NoError(t, client.MoveDataPhysicalChecked(Torrent{Hash: "abc"}, "/downloads/done"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory.set", "d.start", "d.check_hash"), calls)
+
+		client = newClient(t, 1, 0, 0)
+		require.NoError(t, client.MoveDataPhysicalChecked(Torrent{Hash: "abc"}, "/downloads/done"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory_base.set", "d.open", "d.check_hash"), calls)
+	})
+
+	t.Run("close failure restores state", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		closeFault = true
+		err := client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "d.close XMLRPC call failed")
+		require.Equal(t, append(queries, "d.stop", "d.close", "d.start"), calls)
+		require.Nil(t, mv)
+	})
+
+	t.Run("open but stopped", func(t *testing.T) {
+		client := newClient(t, 0, 1, 0)
+		require.NoError(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory.set", "d.open"), calls)
+	})
+
+	t.Run("mv failure restores state", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		mvFault = true
+		require.Error(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done"))
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.start"), calls)
+	})
+
+	t.Run("directory failure moves the data back", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		setFault = true
+		err := client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "d.directory.set XMLRPC call failed")
+		require.Contains(t, err.Error(), "moved back")
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory.set", "execute.throw", "d.start"), calls)
+		require.Equal(t, []interface{}{"", "mv", "--", "/downloads/done/file.iso", "/downloads/incoming/"}, mv)
+	})
+
+	t.Run("directory and move back failure", func(t *testing.T) {
+		client := newClient(t, 1, 1, 1)
+		setFault, mvBackFault = true, true
+		err := client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/done")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "moving /downloads/done/incoming back to /downloads failed")
+		require.Contains(t, err.Error(), "d.directory_base.set XMLRPC call failed")
+		require.Equal(t, append(queries, "d.stop", "d.close", "execute.throw", "d.directory_base.set", "execute.throw"), calls)
+		require.Equal(t, []interface{}{"", "mv", "--", "/downloads/done/incoming", "/downloads/"}, mv)
+	})
+
+	t.Run("same directory", func(t *testing.T) {
+		client := newClient(t, 0, 1, 1)
+		require.NoError(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, "/downloads/incoming/"))
+		require.Equal(t, queries, calls)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		for _, dir := range []string{"relative/path", "/etc", "/downloads-other", "/downloads/../etc", "/downloads/incoming/sub"} {
+			client := newClient(t, 1, 1, 1)
+			require.Error(t, client.MoveDataPhysical(Torrent{Hash: "abc"}, dir), dir)
+			require.Equal(t, queries, calls, dir)
+		}
+	})
+}
+
+func TestDefaultUploadSlots(t *testing.T) {
+	slots := 50
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"throttle.max_uploads": func(args []interface{}) interface{} { return slots },
+		"throttle.max_uploads.set": func(args []interface{}) interface{} {
+			slots = args[1].(int)
+			return 0
+		},
+	})
+
+	n, err := client.DefaultUploadSlots()
+	require.NoError(t, err)
+	require.Equal(t, 50, n)
+
+	require.NoError(t, client.SetDefaultUploadSlots(8))
+	n, err = client.DefaultUploadSlots()
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+
+	require.Error(t, client.SetDefaultUploadSlots(0))
+	require.Error(t, client.SetDefaultUploadSlots(-1))
+	require.Equal(t, 8, slots)
+}
+
+func TestGetTrackers(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"t.multicall": func(args []interface{}) interface{} {
+			require.Equal(t, "abc", args[0])
+			return multicallRows([]map[string]interface{}{
+				{"t.url": "http://tracker.example/announce", "t.type": 1, "t.is_enabled": 1, "t.scrape_complete": 12,
+					"t.scrape_incomplete": 3, "t.min_interval": 1800, "t.activity_time_next": 1635781106},
+				{"t.url": "dht://", "t.type": 3},
+			})(args)
+		},
+	})
+
+	trackers, err := client.GetTrackers(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, []Tracker{
+		{URL: "http://tracker.example/announce", Type: TrackerHTTP, Enabled: true, ScrapeComplete: 12, ScrapeIncomplete: 3,
+			MinInterval: 30 * time.Minute, NextAnnounce: time.Unix(1635781106, 0)},
+		{URL: "dht://", Type: TrackerDHT},
+	}, trackers)
+	require.True(t, trackers[1].NextAnnounce.IsZero())
+}
+
+func TestFilePriority(t *testing.T) {
+	priorities := []int{1, 1}
+	updated := 0
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"f.multicall": func(args []interface{}) interface{} {
+			require.Equal(t, "abc", args[0])
+			rows := []map[string]interface{}{
+				{"f.path": "a.iso", "f.size_bytes": 1024, "f.priority": priorities[0], "f.completed_chunks": 1, "f.size_chunks": 4},
+				{"f.path": "b.txt", "f.size_bytes": 10, "f.priority": priorities[1], "f.completed_chunks": 1, "f.size_chunks": 1},
+			}
+			return multicallRows(rows)(args)
+		},
+		"f.priority.set": func(args []interface{}) interface{} {
+			var index int
+			_, err := fmt.Sscanf(args[0].(string), "abc:f%d", &index)
+			require.NoError(t, err)
+			priorities[index] = args[1].(int)
+			return 0
+		},
+		"d.update_priorities": func(args []interface{}) interface{} {
+			updated++
+			return 0
+		},
+	})
+	tor := Torrent{Hash: "abc"}
+
+	require.NoError(t, client.SetFilePriority(tor, 0, FilePriorityOff))
+	require.NoError(t, client.SetFilePriority(tor, 1, FilePriorityHigh))
+	require.NoError(t, client.UpdatePriorities(tor))
+	require.Equal(t, 1, updated)
+
+	files, err := client.GetFiles(tor)
+	require.NoError(t, err)
+	require.Equal(t, []File{
+		{Path: "a.iso", Size: 1024, Priority: FilePriorityOff, CompletedChunks: 1, SizeChunks: 4},
+		{Path: "b.txt", Size: 10, Priority: FilePriorityHigh, CompletedChunks: 1, SizeChunks: 1},
+	}, files)
+
+	require.Error(t, client.SetFilePriority(tor, -1, FilePriorityOff))
+	require.Error(t, client.SetFilePriority(tor, 0, 3))
+}
+
+func TestStatusETA(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   Status
+		expected time.Duration
+		ok       bool
+	}{
+		{"downloading", Status{Size: 1000, CompletedBytes: 400, DownRate: 100}, 6 * time.Second, true},
+		{"rounded up", Status{Size: 1000, CompletedBytes: 400, DownRate: 250}, 3 * time.Second, true},
+		{"large", Status{Size: 1437206706, DownRate: 1}, 1437206706 * time.Second, true},
+		{"zero rate", Status{Size: 1000, CompletedBytes: 400}, 0, false},
+		{"complete", Status{Completed: true, Size: 1000, CompletedBytes: 1000, DownRate: 100}, 0, false},
+		{"all bytes", Status{Size: 1000, CompletedBytes: 1000, DownRate: 100}, 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			eta, ok := tc.status.ETA()
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, eta)
+		})
+	}
+}
+
+func TestPercentComplete(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   Status
+		expected float64
+	}{
+		{"empty", Status{Size: 1000}, 0},
+		{"half", Status{Size: 1000, CompletedBytes: 500}, 50},
+		{"complete", Status{Completed: true, Size: 1000, CompletedBytes: 1000}, 100},
+		{"no metadata", Status{}, 0},
+		{"complete without size", Status{Completed: true}, 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.status.PercentComplete())
+			tor := Torrent{Size: tc.status.Size, CompletedBytes: tc.status.CompletedBytes, Completed: tc.status.Completed}
+			require.Equal(t, tc.expected, tor.PercentComplete())
+		})
+	}
+	tor := Torrent{Name: "a.iso", Size: 1024, CompletedBytes: 512}
+	require.Contains(t, tor.Pretty(), "\tCompleted: false\n\tComplete: 50.0%\n")
+}
+
+func TestJSON(t *testing.T) {
+	keys := func(v interface{}) map[string]interface{} {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal(b, &m))
+		return m
+	}
+	created := time.Date(2021, 11, 1, 15, 38, 26, 0, time.FixedZone("CET", 3600))
+
+	tor := keys(Torrent{Hash: "ABC", Label: "linux", Created: created, CompletedBytes: 512, State: StateSeeding})
+	require.Equal(t, "ABC", tor["hash"])
+	require.Equal(t, "linux", tor["label"])
+	require.Equal(t, "2021-11-01T15:38:26+01:00", tor["created"])
+	require.Equal(t, float64(512), tor["completed_bytes"])
+	require.Equal(t, float64(StateSeeding), tor["state"])
+	for _, key := range []string{"name", "path", "size", "completed", "ratio", "started", "finished", "uploaded",
+		"downloaded", "priority", "message", "added_at", "finished_at"} {
+		require.Contains(t, tor, key)
+	}
+	require.NotContains(t, tor, "Hash")
+	require.Equal(t, "0001-01-01T00:00:00Z", tor["added_at"], "unset times are the zero time")
+
+	tracker := Tracker{URL: "http://tracker.example/announce", MinInterval: 30 * time.Minute, NextAnnounce: created}
+	trackerKeys := keys(tracker)
+	require.Equal(t, float64(1800), trackerKeys["min_interval_seconds"])
+	require.NotContains(t, trackerKeys, "min_interval")
+	require.NotContains(t, trackerKeys, "MinInterval")
+	require.Equal(t, "http://tracker.example/announce", trackerKeys["url"])
+	b, err := json.Marshal([]Tracker{tracker})
+	require.NoError(t, err)
+	var decoded []Tracker
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Len(t, decoded, 1)
+	require.Equal(t, tracker.MinInterval, decoded[0].MinInterval)
+	require.True(t, tracker.NextAnnounce.Equal(decoded[0].NextAnnounce))
+
+	status := keys(Status{DownRate: 1024, SizeChunks: 5483, SeedersConnected: 3})
+	require.Equal(t, float64(1024), status["down_rate"])
+	require.Equal(t, float64(5483), status["size_chunks"])
+	require.Equal(t, float64(3), status["seeders_connected"])
+	require.Len(t, status, 12)
+
+	file := keys(File{Path: "a.iso", Size: 1024, CompletedChunks: 1})
+	require.Equal(t, map[string]interface{}{"path": "a.iso", "size": float64(1024), "priority": float64(0),
+		"completed_chunks": float64(1), "size_chunks": float64(0)}, file)
+
+	snapshot := keys(TorrentSnapshot{Torrent: Torrent{Hash: "ABC"}, State: DetailedStateSeeding, TrackerCount: 2})
+	require.Equal(t, "ABC", snapshot["hash"])
+	require.Equal(t, float64(DetailedStateSeeding), snapshot["state"])
+	require.Equal(t, float64(2), snapshot["tracker_count"])
+	require.Contains(t, snapshot["status"], "down_rate")
+}
+
+func TestFilePercentComplete(t *testing.T) {
+	for _, tc := range []struct {
+		file     File
+		expected float64
+	}{
+		{File{CompletedChunks: 0, SizeChunks: 4}, 0},
+		{File{CompletedChunks: 1, SizeChunks: 4}, 25},
+		{File{CompletedChunks: 4, SizeChunks: 4}, 100},
+		{File{}, 100},
+	} {
+		require.Equal(t, tc.expected, tc.file.PercentComplete(), "%+v", tc.file)
+	}
+	require.Equal(t, "File:\n\tPath: a.iso\n\tSize: 1024 bytes\n\tComplete: 25.0%\n",
+		(&File{Path: "a.iso", Size: 1024, CompletedChunks: 1, SizeChunks: 4}).Pretty())
+}
+
+func TestGetPeers(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"p.multicall": func(args []interface{}) interface{} {
+			if args[0] == "NOPEERS" {
+				return []interface{}{}
+			}
+			return multicallRows([]map[string]interface{}{
+				{"p.address": "192.0.2.10", "p.port": 51413, "p.client_version": "Transmission 3.0", "p.down_rate": 2048,
+					"p.up_rate": 512, "p.completed_percent": 100, "p.is_encrypted": 1},
+				{"p.address": "2001:db8::1", "p.port": 6881, "p.client_version": "rTorrent 0.9.8"},
+			})(args)
+		},
+	})
+
+	peers, err := client.GetPeers(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, []Peer{
+		{Address: "192.0.2.10", Port: 51413, ClientVersion: "Transmission 3.0", DownRate: 2048, UpRate: 512,
+			CompletedPercent: 100, Encrypted: true},
+		{Address: "2001:db8::1", Port: 6881, ClientVersion: "rTorrent 0.9.8"},
+	}, peers)
+
+	peers, err = client.GetPeers(Torrent{Hash: "NOPEERS"})
+	require.NoError(t, err)
+	require.NotNil(t, peers)
+	require.Empty(t, peers)
+}
+
+func TestAddFile(t *testing.T) {
+	var added []string
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			added = append(added, name)
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"load.raw":       record("load.raw"),
+		"load.raw_start": record("load.raw_start"),
+	})
+
+	require.NoError(t, client.AddFile("testdata/Fedora-i3-Live-x86_64-35.torrent", false))
+	require.NoError(t, client.AddFile("testdata/Fedora-i3-Live-x86_64-35.torrent", true, DLabel.SetValue("fedora")))
+	require.Equal(t, []string{"load.raw", "load.raw_start"}, added)
+
+	t.Run("missing file", func(t *testing.T) {
+		require.Error(t, client.AddFile("testdata/missing.torrent", true))
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		path := t.TempDir() + "/invalid.torrent"
+		require.NoError(t, ioutil.WriteFile(path, []byte("not a torrent"), 0644))
+		err := client.AddFile(path, true)
+		require.Error(t, err)
+		require.Equal(t, torrent.ErrInvalid, errors.Cause(err))
+	})
+	require.Len(t, added, 2)
+}
+
+func TestDeleteWithData(t *testing.T) {
+	var calls [][]interface{}
+	type file struct{ frozen, path string }
+	newClient := func(t *testing.T, directory string, multiFile int, files ...file) *RTorrent {
+		calls = nil
+		record := func(name string) fakeHandler {
+			return func(args []interface{}) interface{} {
+				calls = append(calls, append([]interface{}{name}, args...))
+				if name == "execute.throw" && strings.Contains(args[len(args)-1].(string), "locked") {
+					return xmlrpc.Fault{Code: -503, Message: "Bad return code."}
+				}
+				return 0
+			}
+		}
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"directory.default": func(args []interface{}) interface{} { return "/downloads" },
+			"d.directory":       func(args []interface{}) interface{} { return directory },
+			"d.is_multi_file":   func(args []interface{}) interface{} { return multiFile },
+			"f.multicall": func(args []interface{}) interface{} {
+				require.Equal(t, []interface{}{"abc", 0, "f.frozen_path=", "f.path="}, args)
+				rows := []interface{}{}
+				for _, f := range files {
+					rows = append(rows, []interface{}{f.frozen, f.path})
+				}
+				return rows
+			},
+			"d.erase":       record("d.erase"),
+			"d.custom5.set": record("d.custom5.set"),
+			"execute.throw": record("execute.throw"),
+		})
+		return client
+	}
+
+	t.Run("single file", func(t *testing.T) {
+		client := newClient(t, "/downloads", 0, file{"/downloads/file.iso", "file.iso"})
+		require.NoError(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{
+			{"d.erase", "abc"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/file.iso"},
+		}, calls)
+	})
+
+	t.Run("multi file", func(t *testing.T) {
+		client := newClient(t, "/downloads/files", 1,
+			file{"/downloads/files/sub/a.txt", "sub/a.txt"}, file{"", "sub/deeper/b.txt"}, file{"", "c.txt"})
+		require.NoError(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{
+			{"d.erase", "abc"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/files/sub/a.txt"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/files/sub/deeper/b.txt"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/files/c.txt"},
+			{"execute.throw", "", "rmdir", "--", "/downloads/files/sub/deeper"},
+			{"execute.throw", "", "rmdir", "--", "/downloads/files/sub"},
+			{"execute.throw", "", "rmdir", "--", "/downloads/files"},
+		}, calls)
+	})
+
+	t.Run("shared directory", func(t *testing.T) {
+		// d.directory is the download directory itself, e.g. set with d.directory_base.set
+		client := newClient(t, "/downloads", 1, file{"", "sub/a.txt"}, file{"", "b.txt"})
+		require.NoError(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{
+			{"d.erase", "abc"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/sub/a.txt"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/b.txt"},
+			{"execute.throw", "", "rmdir", "--", "/downloads/sub"},
+		}, calls)
+
+		client = newClient(t, "/", 1, file{"", "downloads/a.txt"})
+		require.Error(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Empty(t, calls)
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		client := newClient(t, "/downloads/files", 1, file{"", "a.txt"}, file{"", "locked.txt"}, file{"", "c.txt"})
+		err := client.DeleteWithData(Torrent{Hash: "abc"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "torrent removed")
+		require.Contains(t, err.Error(), "failed to delete 1 of 3 files: /downloads/files/locked.txt")
+		require.Len(t, calls, 5, "the other files are deleted nonetheless")
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		for _, directory := range []string{"/", "", "relative"} {
+			client := newClient(t, directory, 1, file{"", "a.txt"})
+			require.Error(t, client.DeleteWithData(Torrent{Hash: "abc"}), directory)
+			require.Empty(t, calls, directory)
+		}
+
+		client := newClient(t, "/downloads/files", 1, file{"/etc/passwd", "a.txt"})
+		require.Error(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Empty(t, calls)
+	})
+
+	t.Run("erase data hook", func(t *testing.T) {
+		client := newClient(t, "/", 1).WithEraseDataHook(true)
+		require.NoError(t, client.DeleteWithData(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{{"d.custom5.set", "abc", "1"}, {"d.erase", "abc"}}, calls)
+	})
+}
+
+func TestGlobalStats(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"system.hostname":            value("seedbox"),
+		"network.bind_address":       value("0.0.0.0"),
+		"throttle.global_down.total": value(4096),
+		"throttle.global_up.total":   value(2048),
+		"throttle.global_down.rate":  value(512),
+		"throttle.global_up.rate":    value(256),
+		"view.size": func(args []interface{}) interface{} {
+			require.Equal(t, "main", args[1])
+			return 3
+		},
+	})
+
+	stats, err := client.GlobalStats()
+	require.NoError(t, err)
+	require.Equal(t, GlobalStats{
+		Hostname:  "seedbox",
+		IP:        "0.0.0.0",
+		DownTotal: 4096,
+		UpTotal:   2048,
+		DownRate:  512,
+		UpRate:    256,
+		Torrents:  3,
+	}, stats)
+}
+
+func TestHasValidResume(t *testing.T) {
+	t.Run("supported", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"d.is_hash_checked": func(args []interface{}) interface{} {
+				if args[0] == "checked" {
+					return 1
+				}
+				return 0
+			},
+		})
+		valid, err := client.HasValidResume(Torrent{Hash: "checked"})
+		require.NoError(t, err)
+		require.True(t, valid)
+
+		valid, err = client.HasValidResume(Torrent{Hash: "unchecked"})
+		require.NoError(t, err)
+		require.False(t, valid)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{})
+		valid, err := client.HasValidResume(Torrent{Hash: "abc"})
+		require.NoError(t, err)
+		require.False(t, valid)
+	})
+}
+
+func TestAddAuto(t *testing.T) {
+	var added []string
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			added = append(added, name)
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"load.normal":    record("load.normal"),
+		"load.start":     record("load.start"),
+		"load.raw":       record("load.raw"),
+		"load.raw_start": record("load.raw_start"),
+	})
+
+	require.NoError(t, client.AddAuto("http://example.com/file.torrent"))
+	require.NoError(t, client.AddAuto([]byte("d4:infod4:name1:xee")))
+	client.WithStartOnAdd(true)
+	require.NoError(t, client.AddAuto("http://example.com/file.torrent", DLabel.SetValue("label")))
+	require.NoError(t, client.AddAuto([]byte("d4:infod4:name1:xee")))
+	require.Equal(t, []string{"load.normal", "load.raw", "load.start", "load.raw_start"}, added)
+
+	require.Error(t, client.AddAuto(42))
+	require.Len(t, added, 4)
+}
+
+// bigBuckBunnyMagnet is the magnet link of the sample torrent of WebTorrent, which has many seeders
+const bigBuckBunnyMagnet = "magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c&dn=Big+Buck+Bunny" +
+	"&tr=udp%3A%2F%2Ftracker.opentrackr.org%3A1337"
+
+func TestAddMagnet(t *testing.T) {
+	var calls []string
+	var loaded []interface{}
+	meta := 1
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, name)
+			loaded = args[1].([]interface{})
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"load.normal": record("load.normal"),
+		"load.start":  record("load.start"),
+		"f.multicall": func(args []interface{}) interface{} {
+			if meta == 1 {
+				return []interface{}{[]interface{}{"DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C.meta", 0, 1, 0, 1}}
+			}
+			return []interface{}{[]interface{}{"Big Buck Bunny.mp4", 276134947, 1, 0, 1054}}
+		},
+		"d.is_meta": func(args []interface{}) interface{} { return meta },
+	})
+
+	require.NoError(t, client.AddMagnet(bigBuckBunnyMagnet, DLabel.SetValue("movies")))
+	require.Equal(t, []interface{}{[]byte(bigBuckBunnyMagnet), `d.custom1.set="movies"`}, loaded)
+	require.NoError(t, client.AddMagnetStopped(bigBuckBunnyMagnet))
+	require.Equal(t, []string{"load.start", "load.normal"}, calls)
+
+	for _, uri := range []string{"http://example.com/file.torrent", "magnet:?dn=no+hash", "not a uri\x7f"} {
+		require.Error(t, client.AddMagnet(uri), uri)
+	}
+	require.Len(t, calls, 2)
+
+	t.Run("files without metadata", func(t *testing.T) {
+		files, err := client.GetFiles(Torrent{Hash: "DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C"})
+		require.NoError(t, err)
+		require.NotNil(t, files)
+		require.Empty(t, files)
+
+		meta = 0
+		files, err = client.GetFiles(Torrent{Hash: "DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C"})
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, "Big Buck Bunny.mp4", files[0].Path)
+	})
+}
+
+func TestAddReturningHash(t *testing.T) {
+	var calls []string
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, name)
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"load.normal":    record("load.normal"),
+		"load.start":     record("load.start"),
+		"load.raw":       record("load.raw"),
+		"load.raw_start": record("load.raw_start"),
+	})
+
+	data, err := ioutil.ReadFile("testdata/Fedora-i3-Live-x86_64-35.torrent")
+	require.NoError(t, err)
+	hash, err := client.AddReturningHash(data)
+	require.NoError(t, err)
+	require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", hash)
+
+	hash, err = client.WithStartOnAdd(true).AddReturningHash(bigBuckBunnyMagnet)
+	require.NoError(t, err)
+	require.Equal(t, "DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C", hash)
+	require.Equal(t, []string{"load.raw", "load.start"}, calls)
+
+	for _, source := range []interface{}{"https://example.com/file.torrent", []byte("not a torrent"), 42} {
+		_, err := client.AddReturningHash(source)
+		require.Error(t, err, source)
+	}
+	require.Len(t, calls, 2)
+}
+
+func TestBulkOperations(t *testing.T) {
+	var calls [][]interface{}
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, append([]interface{}{name}, args...))
+			if args[0] == "missing" {
+				return xmlrpc.Fault{Code: -501, Message: "Could not find info-hash."}
+			}
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.start":       record("d.start"),
+		"d.stop":        record("d.stop"),
+		"d.custom1.set": record("d.custom1.set"),
+	})
+	ts := []Torrent{{Hash: "ABC"}, {Hash: "DEF"}}
+
+	require.NoError(t, client.StartAll(ts))
+	require.NoError(t, client.StopAll(ts))
+	require.NoError(t, client.SetLabelAll(ts, "movies"))
+	require.Equal(t, [][]interface{}{
+		{"d.start", "ABC"}, {"d.start", "DEF"},
+		{"d.stop", "ABC"}, {"d.stop", "DEF"},
+		{"d.custom1.set", "ABC", "movies"}, {"d.custom1.set", "DEF", "movies"},
+	}, calls)
+
+	t.Run("partial failure", func(t *testing.T) {
+		calls = nil
+		err := client.StopAll([]Torrent{{Hash: "ABC"}, {Hash: "missing"}, {Hash: "DEF"}})
+		require.Error(t, err)
+		batchErr, ok := err.(*BatchError)
+		require.True(t, ok, err.Error())
+		require.Len(t, batchErr.Errors, 1)
+		require.Contains(t, batchErr.Errors["missing"].Error(), "Could not find info-hash.")
+		require.Contains(t, err.Error(), "missing: d.stop XMLRPC call failed")
+		require.Len(t, calls, 3, "the batch is not aborted")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.StartAll(nil))
+		require.Empty(t, calls)
+	})
+}
+
+func TestServerTime(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"system.time_seconds": func(args []interface{}) interface{} { return 1635781106 },
+	})
+
+	now, err := client.ServerTime()
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1635781106, 0), now)
+}
+
+func TestEncryptionMode(t *testing.T) {
+	var options []interface{}
+	handlers := map[string]fakeHandler{
+		"protocol.encryption.set": func(args []interface{}) interface{} {
+			options = args[1:]
+			return 0
+		},
+	}
+	fakeVariables(handlers)
+	client, _ := newFakeRTorrent(t, handlers)
+
+	t.Run("set", func(t *testing.T) {
+		require.NoError(t, client.SetEncryptionMode("allow_incoming, try_outgoing,enable_retry"))
+		require.Equal(t, []interface{}{"allow_incoming", "try_outgoing", "enable_retry"}, options)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		options = nil
+		for _, mode := range []string{"", "always", "require,", "require,sometimes"} {
+			require.Error(t, client.SetEncryptionMode(mode), mode)
+		}
+		require.Nil(t, options)
+	})
+
+	t.Run("read back", func(t *testing.T) {
+		handlers := map[string]fakeHandler{
+			"protocol.encryption.set": func(args []interface{}) interface{} { return 0 },
+		}
+		fakeVariables(handlers)
+		client, _ := newFakeRTorrent(t, handlers)
+		mode, err := client.EncryptionMode()
+		require.NoError(t, err)
+		require.Empty(t, mode, "not set by this library yet")
+
+		require.NoError(t, client.SetEncryptionMode("allow_incoming, require"))
+		mode, err = client.EncryptionMode()
+		require.NoError(t, err)
+		require.Equal(t, "allow_incoming,require", mode)
+
+		// another client of the same rTorrent sees it too
+		other, _ := newFakeRTorrent(t, handlers)
+		require.NoError(t, other.SetEncryptionMode("try_outgoing,enable_retry"))
+		mode, err = client.EncryptionMode()
+		require.NoError(t, err)
+		require.Equal(t, "try_outgoing,enable_retry", mode)
+	})
+}
+
+// fakeVariables adds a method.insert handler to handlers, for the string variables it creates
+func fakeVariables(handlers map[string]fakeHandler) {
+	handlers["method.insert"] = func(args []interface{}) interface{} {
+		name, value := args[1].(string), args[3]
+		handlers[name] = func(args []interface{}) interface{} { return value }
+		handlers[name+".set"] = func(args []interface{}) interface{} {
+			value = args[1]
+			return 0
+		}
+		return 0
+	}
+}
+
+func TestCleanupCompleted(t *testing.T) {
+	var calls [][]interface{}
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, append([]interface{}{name}, args...))
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": func(args []interface{}) interface{} {
+			require.Equal(t, "seeding", args[1])
+			return multicallRows([]map[string]interface{}{
+				{"d.hash": "reached", "d.complete": 1, "d.ratio": 2000, "d.directory": "/downloads", "d.name": "a.iso"},
+				{"d.hash": "exact", "d.complete": 1, "d.ratio": 1500, "d.directory": "/downloads/b", "d.name": "b", "d.is_multi_file": 1},
+				{"d.hash": "low", "d.complete": 1, "d.ratio": 1499, "d.directory": "/downloads", "d.name": "c.iso"},
+				{"d.hash": "incomplete", "d.complete": 0, "d.ratio": 3000, "d.directory": "/downloads", "d.name": "d.iso"},
+			})(args)
+		},
+		"directory.default": func(args []interface{}) interface{} { return "/downloads" },
+		"d.directory": func(args []interface{}) interface{} {
+			return map[string]string{"reached": "/downloads", "exact": "/downloads/b"}[args[0].(string)]
+		},
+		"d.is_multi_file": func(args []interface{}) interface{} {
+			return map[string]int{"reached": 0, "exact": 1}[args[0].(string)]
+		},
+		"f.multicall": func(args []interface{}) interface{} {
+			if args[0] == "reached" {
+				return []interface{}{[]interface{}{"/downloads/a.iso", "a.iso"}}
+			}
+			return []interface{}{[]interface{}{"", "b1.iso"}, []interface{}{"", "b2.iso"}}
+		},
+		"d.erase":       record("d.erase"),
+		"d.custom5.set": record("d.custom5.set"),
+		"execute.throw": record("execute.throw"),
+	})
+
+	t.Run("without data", func(t *testing.T) {
+		calls = nil
+		hashes, err := client.CleanupCompleted(ViewSeeding, 1.5, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"reached", "exact"}, hashes)
+		require.Equal(t, [][]interface{}{{"d.erase", "reached"}, {"d.erase", "exact"}}, calls)
+	})
+
+	t.Run("with data", func(t *testing.T) {
+		calls = nil
+		hashes, err := client.CleanupCompleted(ViewSeeding, 1.5, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"reached", "exact"}, hashes)
+		require.Equal(t, [][]interface{}{
+			{"d.erase", "reached"},
+			{"d.erase", "exact"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/a.iso"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/b/b1.iso"},
+			{"execute.throw", "", "rm", "-f", "--", "/downloads/b/b2.iso"},
+			{"execute.throw", "", "rmdir", "--", "/downloads/b"},
+		}, calls)
+	})
+
+	t.Run("erase data hook", func(t *testing.T) {
+		calls = nil
+		hashes, err := client.WithEraseDataHook(true).CleanupCompleted(ViewSeeding, 1.5, true)
+		client.WithEraseDataHook(false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"reached", "exact"}, hashes)
+		require.Equal(t, [][]interface{}{
+			{"d.custom5.set", "reached", "1"},
+			{"d.erase", "reached"},
+			{"d.custom5.set", "exact", "1"},
+			{"d.erase", "exact"},
+		}, calls)
+	})
+
+	t.Run("nothing matches", func(t *testing.T) {
+		calls = nil
+		hashes, err := client.CleanupCompleted(ViewSeeding, 5, true)
+		require.NoError(t, err)
+		require.Empty(t, hashes)
+		require.Empty(t, calls)
+	})
+
+	t.Run("invalid ratio", func(t *testing.T) {
+		calls = nil
+		for _, ratio := range []float64{0, -1} {
+			_, err := client.CleanupCompleted(ViewSeeding, ratio, false)
+			require.Error(t, err)
+		}
+		require.Empty(t, calls)
+	})
+}
+
+func TestTotalPeers(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.peers_connected": 12},
+			{"d.peers_connected": 0},
+			{"d.peers_connected": 30},
+		}),
+	})
+
+	total, err := client.TotalPeers()
+	require.NoError(t, err)
+	require.Equal(t, 42, total)
+}
+
+func TestAssignLabel(t *testing.T) {
+	views := []interface{}{"main", "started", "label_movies"}
+	var calls [][]interface{}
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, append([]interface{}{name}, args...))
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"view.list": func(args []interface{}) interface{} { return views },
+		"view.add": func(args []interface{}) interface{} {
+			views = append(views, args[1])
+			return record("view.add")(args)
+		},
+		"d.custom1.set":            record("d.custom1.set"),
+		"d.views.push_back_unique": record("d.views.push_back_unique"),
+		"view.set_visible":         record("view.set_visible"),
+	})
+
+	t.Run("new view", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.AssignLabel(Torrent{Hash: "abc"}, "linux"))
+		require.Equal(t, [][]interface{}{
+			{"d.custom1.set", "abc", "linux"},
+			{"view.add", "", "label_linux"},
+			{"d.views.push_back_unique", "abc", "label_linux"},
+			{"view.set_visible", "abc", "label_linux"},
+		}, calls)
+	})
+
+	t.Run("existing view", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.AssignLabel(Torrent{Hash: "def"}, "movies"))
+		require.Equal(t, [][]interface{}{
+			{"d.custom1.set", "def", "movies"},
+			{"d.views.push_back_unique", "def", "label_movies"},
+			{"view.set_visible", "def", "label_movies"},
+		}, calls)
+	})
+}
+
+func TestStartStopTorrent(t *testing.T) {
+	var calls [][]interface{}
+	isOpen := 0
+	record := func(name string) fakeHandler {
+		return func(args []interface{}) interface{} {
+			calls = append(calls, append([]interface{}{name}, args...))
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.start":      record("d.start"),
+		"d.stop":       record("d.stop"),
+		"d.pause":      record("d.pause"),
+		"d.resume":     record("d.resume"),
+		"d.check_hash": record("d.check_hash"),
+		"d.open": func(args []interface{}) interface{} {
+			isOpen = 1
+			return record("d.open")(args)
+		},
+		"d.close": func(args []interface{}) interface{} {
+			isOpen = 0
+			return record("d.close")(args)
+		},
+		"d.is_open": func(args []interface{}) interface{} { return isOpen },
+	})
+
+	require.NoError(t, client.StartTorrent(Torrent{Hash: "abc"}))
+	require.NoError(t, client.StopTorrent(Torrent{Hash: "abc"}))
+	require.Equal(t, [][]interface{}{{"d.start", "abc"}, {"d.stop", "abc"}}, calls)
+
+	t.Run("check hash", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.CheckHash(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{{"d.check_hash", "abc"}}, calls)
+	})
+
+	t.Run("open and close", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.OpenTorrent(Torrent{Hash: "abc"}))
+		open, err := client.IsOpen(Torrent{Hash: "abc"})
+		require.NoError(t, err)
+		require.True(t, open)
+
+		require.NoError(t, client.CloseTorrent(Torrent{Hash: "abc"}))
+		open, err = client.IsOpen(Torrent{Hash: "abc"})
+		require.NoError(t, err)
+		require.False(t, open)
+		require.Equal(t, [][]interface{}{{"d.open", "abc"}, {"d.close", "abc"}}, calls)
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		calls = nil
+		require.NoError(t, client.PauseTorrent(Torrent{Hash: "abc"}))
+		require.NoError(t, client.ResumeTorrent(Torrent{Hash: "abc"}))
+		require.Equal(t, [][]interface{}{{"d.pause", "abc"}, {"d.resume", "abc"}}, calls)
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{})
+		err := client.StartTorrent(Torrent{Hash: "abc"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "d.start XMLRPC call failed")
+	})
+}
+
+func TestIsPartiallySelected(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		rows     []interface{}
+		expected bool
+	}{
+		{"all selected", []interface{}{[]interface{}{1}, []interface{}{2}}, false},
+		{"file off", []interface{}{[]interface{}{1}, []interface{}{0}}, true},
+		{"short rows", []interface{}{[]interface{}{1}, []interface{}{}, "not a row"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+				"f.multicall": func(args []interface{}) interface{} { return tc.rows },
+			})
+			partial, err := client.IsPartiallySelected(Torrent{Hash: "abc"})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, partial)
+		})
+	}
+}
+
+func TestWantedSize(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"f.multicall": multicallRows([]map[string]interface{}{
+			{"f.size_bytes": 1000, "f.priority": 1},
+			{"f.size_bytes": 200, "f.priority": 0},
+			{"f.size_bytes": 30, "f.priority": 2},
+		}),
+	})
+
+	size, err := client.WantedSize(Torrent{Hash: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1030), size)
+
+	t.Run("short rows", func(t *testing.T) {
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"f.multicall": func(args []interface{}) interface{} {
+				return []interface{}{[]interface{}{1000, 1}, []interface{}{200}, "not a row"}
+			},
+		})
+		size, err := client.WantedSize(Torrent{Hash: "abc"})
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), size)
+	})
+}
+
+func TestServerIdentity(t *testing.T) {
+	pid, startup := 1234, 1635781106
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"system.pid":          func(args []interface{}) interface{} { return pid },
+		"system.startup_time": func(args []interface{}) interface{} { return startup },
+	})
+
+	identity, err := client.ServerIdentity()
+	require.NoError(t, err)
+	require.Equal(t, "1234-1635781106", identity)
+
+	p, err := client.PID()
+	require.NoError(t, err)
+	require.Equal(t, 1234, p)
+	started, err := client.StartupTime()
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1635781106, 0), started)
+
+	t.Run("restart with the same pid", func(t *testing.T) {
+		startup += 60
+		restarted, err := client.ServerIdentity()
+		require.NoError(t, err)
+		require.NotEqual(t, identity, restarted)
+	})
+}
+
+func TestGetTorrent(t *testing.T) {
+	values := map[string]interface{}{
+		"d.hash":               "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		"d.name":               "Fedora-i3-Live-x86_64-35",
+		"d.directory":          "/downloads/Fedora-i3-Live-x86_64-35.iso",
+		"d.size_bytes":         1437206706,
+		"d.custom1":            "linux",
+		"d.complete":           1,
+		"d.completed_bytes":    1437206706,
+		"d.ratio":              1500,
+		"d.creation_date":      1635243120,
+		"d.timestamp.started":  1635300000,
+		"d.timestamp.finished": 1635303600,
+		"d.up.total":           2155810,
+		"d.down.total":         1437206706,
+		"d.priority":           3,
+		"d.state":              1,
+		"d.is_active":          1,
+		"d.hashing":            0,
+		"d.message":            "",
+		"d.load_date":          1635299000,
+	}
+	handlers := map[string]fakeHandler{}
+	for cmd, v := range values {
+		v := v
+		handlers[cmd] = func(args []interface{}) interface{} {
+			if args[0] != "299939CFF841ED7FFCA2B3C2A35711C12589632B" {
+				return xmlrpc.Fault{Code: -501, Message: "Could not find info-hash."}
+			}
+			return v
+		}
+	}
+	handlers["d.multicall2"] = multicallRows([]map[string]interface{}{values})
+	client, server := newFakeRTorrent(t, handlers)
+	requests := 0
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		handler.ServeHTTP(w, req)
+	})
+
+	torrent, err := client.GetTorrent("299939CFF841ED7FFCA2B3C2A35711C12589632B")
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+	require.Equal(t, Torrent{
+		Hash:           "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		Name:           "Fedora-i3-Live-x86_64-35",
+		Path:           "/downloads/Fedora-i3-Live-x86_64-35.iso",
+		Size:           1437206706,
+		Label:          "linux",
+		Completed:      true,
+		Ratio:          1.5,
+		Created:        time.Unix(1635243120, 0),
+		Started:        time.Unix(1635300000, 0),
+		Finished:       time.Unix(1635303600, 0),
+		Uploaded:       2155810,
+		Downloaded:     1437206706,
+		Priority:       PriorityHigh,
+		State:          StateSeeding,
+		AddedAt:        time.Unix(1635299000, 0),
+		FinishedAt:     time.Unix(1635303600, 0),
+		CompletedBytes: 1437206706,
+	}, torrent)
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	require.Equal(t, []Torrent{torrent}, torrents)
+
+	_, err = client.GetTorrent("MISSING")
+	require.Error(t, err)
+}
+
+func TestGetTorrentSnapshot(t *testing.T) {
+	values := map[string]interface{}{
+		"d.hash":            "abc",
+		"d.name":            "Fedora",
+		"d.directory":       "/downloads/Fedora",
+		"d.size_bytes":      1437206706,
+		"d.custom1":         "linux",
+		"d.complete":        1,
+		"d.completed_bytes": 1437206706,
+		"d.ratio":           1500,
+		"d.up.rate":         2048,
+		"d.up.total":        2155810,
+		"d.down.total":      1437206706,
+		"d.is_open":         1,
+		"d.is_active":       1,
+		"d.state":           1,
+		"d.tracker_size":    2,
+		"d.peers_complete":  3,
+	}
+	handlers := map[string]fakeHandler{}
+	for _, cmd := range InspectFields {
+		handlers[cmd.Cmd()] = nil
+	}
+	for _, cmd := range []string{"d.down.rate", "d.hashing", "d.tracker_size", "d.peers_complete", "d.peers_accounted",
+		"d.chunk_size"} {
+		handlers[cmd] = nil
+	}
+	for cmd := range handlers {
+		cmd := cmd
+		handlers[cmd] = func(args []interface{}) interface{} {
+			require.Equal(t, []interface{}{"abc"}, args)
+			if v, ok := values[cmd]; ok {
+				return v
+			}
+			if cmd == "d.message" {
+				return ""
+			}
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, handlers)
+
+	s, err := client.GetTorrentSnapshot("abc")
+	require.NoError(t, err)
+	require.Equal(t, "abc", s.Hash)
+	require.Equal(t, "Fedora", s.Name)
+	require.Equal(t, "/downloads/Fedora", s.Path)
+	require.Equal(t, "linux", s.Label)
+	require.Equal(t, int64(1437206706), s.Size)
+	require.True(t, s.Completed)
+	require.Equal(t, 1.5, s.Ratio)
+	require.Equal(t, int64(2155810), s.Uploaded)
+	require.Equal(t, Status{Completed: true, CompletedBytes: 1437206706, UpRate: 2048, Ratio: 1.5, Size: 1437206706,
+		SeedersConnected: 3}, s.Status)
+	require.Equal(t, DetailedStateSeeding, s.State)
+	require.Equal(t, StateSeeding, s.Torrent.State)
+	require.Equal(t, 2, s.TrackerCount)
+
+	t.Run("stopped while open", func(t *testing.T) {
+		values["d.state"] = 0
+		defer func() { values["d.state"] = 1 }()
+		s, err := client.GetTorrentSnapshot("abc")
+		require.NoError(t, err)
+		require.Equal(t, DetailedStateStopped, s.State)
+		require.Equal(t, StateStopped, s.Torrent.State)
+	})
+}
+
+func TestCustomFields(t *testing.T) {
+	values := map[string]string{}
+	handlers := map[string]fakeHandler{}
+	for _, field := range []string{"d.custom1", "d.custom2", "d.custom3", "d.custom4", "d.custom5"} {
+		field := field
+		handlers[field] = func(args []interface{}) interface{} { return values[args[0].(string)+"/"+field] }
+		handlers[field+".set"] = func(args []interface{}) interface{} {
+			values[args[0].(string)+"/"+field] = args[1].(string)
+			return 0
+		}
+	}
+	client, _ := newFakeRTorrent(t, handlers)
+	tor := Torrent{Hash: "abc"}
+
+	require.NoError(t, client.SetCustom(tor, 3, "release group"))
+	value, err := client.GetCustom(tor, 3)
+	require.NoError(t, err)
+	require.Equal(t, "release group", value)
+	require.Equal(t, map[string]string{"abc/d.custom3": "release group"}, values)
+
+	t.Run("label is custom1", func(t *testing.T) {
+		require.NoError(t, client.SetLabel(tor, "linux"))
+		value, err := client.GetCustom(tor, 1)
+		require.NoError(t, err)
+		require.Equal(t, "linux", value)
+	})
+
+	t.Run("invalid number", func(t *testing.T) {
+		for _, n := range []int{0, 6, -1} {
+			require.Error(t, client.SetCustom(tor, n, "x"))
+			_, err := client.GetCustom(tor, n)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestCustomKeys(t *testing.T) {
+	values := map[string]string{}
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.custom": func(args []interface{}) interface{} { return values[args[0].(string)+"/"+args[1].(string)] },
+		"d.custom.set": func(args []interface{}) interface{} {
+			values[args[0].(string)+"/"+args[1].(string)] = args[2].(string)
+			return 0
+		},
+	})
+	tor := Torrent{Hash: "abc"}
+
+	t.Run("round trip", func(t *testing.T) {
+		key, value := `app<state>&"'`, `{"tags":["<a>","b & c"],"note":"it's \"done\""}`
+		require.NoError(t, client.SetCustomKey(tor, key, value))
+		require.Equal(t, value, values["abc/"+key])
+
+		stored, err := client.GetCustomKey(tor, key)
+		require.NoError(t, err)
+		require.Equal(t, value, stored)
+	})
+
+	t.Run("never set", func(t *testing.T) {
+		stored, err := client.GetCustomKey(tor, "missing")
+		require.NoError(t, err)
+		require.Empty(t, stored)
+	})
+
+	t.Run("empty key", func(t *testing.T) {
+		require.Error(t, client.SetCustomKey(tor, "", "value"))
+		_, err := client.GetCustomKey(tor, "")
+		require.Error(t, err)
+	})
+}
+
+func TestCaller(t *testing.T) {
+	client := NewWithCaller(fakeCaller{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "ABC", "d.name": "Fedora-i3-Live-x86_64-35", "d.size_bytes": int64(1437206706), "d.custom1": "linux"},
+			{"d.hash": "DEF", "d.name": "empty"},
+		}),
+		"system.client_version":  func(args []interface{}) interface{} { return "0.9.8" },
+		"system.library_version": func(args []interface{}) interface{} { return "0.13.8" },
+	}).WithTimeout(time.Second)
+
+	torrents, err := client.GetTorrents(ViewMain)
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+	require.Equal(t, "ABC", torrents[0].Hash)
+	require.Equal(t, "Fedora-i3-Live-x86_64-35", torrents[0].Name)
+	require.Equal(t, int64(1437206706), torrents[0].Size)
+	require.Equal(t, "linux", torrents[0].Label)
+	require.Equal(t, "DEF", torrents[1].Hash)
+
+	t.Run("multicall", func(t *testing.T) {
+		versions, err := client.Versions()
+		require.NoError(t, err)
+		require.Equal(t, Versions{Client: "0.9.8", Library: "0.13.8"}, versions)
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		_, err := client.APIVersion()
+		require.NoError(t, err, "method not found is detected through the caller")
+		_, err = client.PID()
+		require.Error(t, err)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.GetTorrentsContext(ctx, ViewMain)
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+	})
+
+	t.Run("diagnose", func(t *testing.T) {
+		_, err := client.Diagnose()
+		require.Error(t, err)
+	})
+}
+
+func ExampleNewWithCaller() {
+	// any Caller can be injected, here a fake answering d.multicall2 with canned rows, without a rTorrent server
+	client := NewWithCaller(fakeCaller{
+		"d.multicall2": multicallRows([]map[string]interface{}{
+			{"d.hash": "299939CFF841ED7FFCA2B3C2A35711C12589632B", "d.name": "Fedora-i3-Live-x86_64-35",
+				"d.size_bytes": 1437206706, "d.custom1": "linux"},
+		}),
+	})
+
+	torrents, err := client.GetTorrents(ViewMain)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, tor := range torrents {
+		fmt.Println(tor.Hash, tor.Name, tor.Size, tor.Label)
+	}
+	// Output: 299939CFF841ED7FFCA2B3C2A35711C12589632B Fedora-i3-Live-x86_64-35 1437206706 linux
+}
+
+func TestWithRetry(t *testing.T) {
+	var mu sync.Mutex
+	var requests, failures int
+	reset := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests, failures = 0, n
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		fail := requests <= failures
+		mu.Unlock()
+		if fail {
+			// drop the connection like an overloaded rTorrent
+			panic(http.ErrAbortHandler)
+		}
+		name, _, _, err := xmlrpc.Unmarshal(req.Body)
+		require.NoError(t, err)
+		if name == "system.pid" {
+			require.NoError(t, xmlrpc.Marshal(w, "", xmlrpc.Fault{Code: -506, Message: "Method 'system.pid' not defined"}))
+			return
+		}
+		require.NoError(t, xmlrpc.Marshal(w, "", "0.9.8"))
+	}))
+	defer server.Close()
+	client := New(server.URL, false).WithRetry(3, time.Millisecond)
+
+	t.Run("succeeds after failures", func(t *testing.T) {
+		reset(2)
+		version, err := client.ClientVersion()
+		require.NoError(t, err)
+		require.Equal(t, "0.9.8", version)
+		require.Equal(t, 3, count())
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		reset(3)
+		_, err := client.ClientVersion()
+		require.Error(t, err)
+		require.Equal(t, 3, count())
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		reset(0)
+		_, err := client.PID()
+		require.Error(t, err)
+		require.Equal(t, 1, count(), "faults are not retried")
+	})
+
+	t.Run("context", func(t *testing.T) {
+		reset(3)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.GetTorrentsContext(ctx, ViewMain)
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+		require.Equal(t, 0, count())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := New(server.URL, false).WithRetry(3, time.Millisecond).WithRetry(1, 0)
+		reset(1)
+		_, err := client.ClientVersion()
+		require.Error(t, err)
+		require.Equal(t, 1, count())
+	})
+}
+
+func TestContextCancel(t *testing.T) {
+	client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+		"d.multicall2": multicallRows([]map[string]interface{}{{"d.hash": "ABC"}}),
+	})
+
+	t.Run("background", func(t *testing.T) {
+		torrents, err := client.GetTorrentsContext(context.Background(), ViewMain)
+		require.NoError(t, err)
+		require.Len(t, torrents, 1)
+		require.Equal(t, "ABC", torrents[0].Hash)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := client.GetTorrentsContext(ctx, ViewMain)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+
+		_, err = client.GetStatusContext(ctx, Torrent{Hash: "ABC"})
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+		err = client.AddContext(ctx, "http://example.com/some.torrent")
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+	})
+
+	t.Run("cancelled in flight", func(t *testing.T) {
+		received, release := make(chan struct{}), make(chan struct{})
+		client, _ := newFakeRTorrent(t, map[string]fakeHandler{
+			"d.multicall2": func(args []interface{}) interface{} {
+				close(received)
+				// hang like an unresponsive rTorrent until the test is over
+				<-release
+				return []interface{}{}
+			},
+		})
+		// registered after the server, so the handler is released before the server is closed
+		t.Cleanup(func() { close(release) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-received
+			cancel()
+		}()
+		done := make(chan error)
+		go func() {
+			_, err := client.GetTorrentsContext(ctx, ViewMain)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			require.Error(t, err)
+			require.True(t, errors.Is(err, context.Canceled), err.Error())
+		case <-time.After(5 * time.Second):
+			t.Fatal("call was not aborted when the context was cancelled")
+		}
+	})
+}
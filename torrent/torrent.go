@@ -0,0 +1,286 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalid is returned when the data is not valid bencode
+var ErrInvalid = errors.New("invalid bencoded data")
+
+// Metainfo is the metadata of a .torrent file, as returned by Parse
+type Metainfo struct {
+	Hash  string `json:"hash"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Files []File `json:"files"`
+}
+
+// File is a file of a torrent, its path is relative to the directory of the torrent for multi-file torrents
+// and the name of the torrent for single-file ones
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// InfoHash computes the info hash of the given .torrent file data
+// The hash is the SHA1 of the bencoded "info" dictionary, returned as upper case hex like rTorrent reports it in d.hash
+func InfoHash(data []byte) (string, error) {
+	info, err := infoDict(data)
+	if err != nil {
+		return "", err
+	}
+	return hashOf(info), nil
+}
+
+// Parse parses the given .torrent file data into its info hash, name, total size and files
+// Both single-file ("length") and multi-file ("files") torrents are supported, unknown keys are ignored.
+func Parse(data []byte) (*Metainfo, error) {
+	raw, err := infoDict(data)
+	if err != nil {
+		return nil, err
+	}
+	info, err := (&decoder{data: raw}).decodeDict()
+	if err != nil {
+		return nil, err
+	}
+	m := &Metainfo{Hash: hashOf(raw)}
+	var ok bool
+	if m.Name, ok = info["name"].(string); !ok {
+		return nil, errors.Wrap(ErrInvalid, "missing name")
+	}
+
+	if length, ok := info["length"].(int64); ok {
+		m.Size = length
+		m.Files = []File{{Path: m.Name, Size: length}}
+		return m, nil
+	}
+	files, ok := info["files"].([]interface{})
+	if !ok {
+		return nil, errors.Wrap(ErrInvalid, "missing length or files")
+	}
+	m.Files = make([]File, 0, len(files))
+	for i, f := range files {
+		file, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalid, "file %d isn't a dictionary", i)
+		}
+		length, ok := file["length"].(int64)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalid, "missing length of file %d", i)
+		}
+		elements, _ := file["path"].([]interface{})
+		path := make([]string, 0, len(elements))
+		for _, e := range elements {
+			s, ok := e.(string)
+			if !ok {
+				return nil, errors.Wrapf(ErrInvalid, "invalid path of file %d", i)
+			}
+			path = append(path, s)
+		}
+		if len(path) == 0 {
+			return nil, errors.Wrapf(ErrInvalid, "missing path of file %d", i)
+		}
+		m.Size += length
+		m.Files = append(m.Files, File{Path: strings.Join(path, "/"), Size: length})
+	}
+	return m, nil
+}
+
+// infoDict returns the bencoded "info" dictionary of the .torrent file data
+func infoDict(data []byte) ([]byte, error) {
+	d := &decoder{data: data}
+	if err := d.expect('d'); err != nil {
+		return nil, err
+	}
+	var info []byte
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 'e' {
+			break
+		}
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		if _, err := d.decode(); err != nil {
+			return nil, err
+		}
+		if key == "info" {
+			info = data[start:d.pos]
+		}
+	}
+	if info == nil || info[0] != 'd' {
+		return nil, errors.Wrap(ErrInvalid, "missing info dictionary")
+	}
+	return info, nil
+}
+
+func hashOf(info []byte) string {
+	sum := sha1.Sum(info)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// MagnetInfoHash returns the info hash of the given magnet link, from its "xt=urn:btih:" parameter
+// The hash is returned as upper case hex like InfoHash, it may be hex or base32 encoded in the link.
+func MagnetInfoHash(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "magnet" {
+		return "", errors.Errorf("invalid magnet link: %q", uri)
+	}
+	for _, xt := range u.Query()["xt"] {
+		if len(xt) < len("urn:btih:") || !strings.EqualFold(xt[:len("urn:btih:")], "urn:btih:") {
+			continue
+		}
+		hash := xt[len("urn:btih:"):]
+		switch len(hash) {
+		case 40:
+			if _, err := hex.DecodeString(hash); err == nil {
+				return strings.ToUpper(hash), nil
+			}
+		case 32:
+			if sum, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+				return strings.ToUpper(hex.EncodeToString(sum)), nil
+			}
+		}
+		return "", errors.Errorf("invalid info hash in magnet link: %q", hash)
+	}
+	return "", errors.Errorf("magnet link without BitTorrent info hash: %q", uri)
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errors.Wrap(ErrInvalid, "unexpected end of data")
+	}
+	return d.data[d.pos], nil
+}
+
+func (d *decoder) expect(c byte) error {
+	got, err := d.peek()
+	if err != nil {
+		return err
+	}
+	if got != c {
+		return errors.Wrapf(ErrInvalid, "expected '%c' at offset %d, found '%c'", c, d.pos, got)
+	}
+	d.pos++
+	return nil
+}
+
+func (d *decoder) decode() (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == 'i':
+		return d.decodeInt()
+	case c == 'l':
+		return d.decodeList()
+	case c == 'd':
+		return d.decodeDict()
+	case c >= '0' && c <= '9':
+		return d.decodeString()
+	}
+	return nil, errors.Wrapf(ErrInvalid, "unexpected '%c' at offset %d", c, d.pos)
+}
+
+func (d *decoder) decodeInt() (int64, error) {
+	if err := d.expect('i'); err != nil {
+		return 0, err
+	}
+	end := d.pos
+	for end < len(d.data) && d.data[end] != 'e' {
+		end++
+	}
+	if end >= len(d.data) {
+		return 0, errors.Wrap(ErrInvalid, "unterminated integer")
+	}
+	i, err := strconv.ParseInt(string(d.data[d.pos:end]), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalid, "bad integer at offset %d", d.pos)
+	}
+	d.pos = end + 1
+	return i, nil
+}
+
+func (d *decoder) decodeString() (string, error) {
+	colon := d.pos
+	for colon < len(d.data) && d.data[colon] != ':' {
+		colon++
+	}
+	if colon >= len(d.data) {
+		return "", errors.Wrap(ErrInvalid, "unterminated string length")
+	}
+	length, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || length < 0 {
+		return "", errors.Wrapf(ErrInvalid, "bad string length at offset %d", d.pos)
+	}
+	start := colon + 1
+	if length > len(d.data)-start {
+		return "", errors.Wrapf(ErrInvalid, "string at offset %d exceeds data", d.pos)
+	}
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
+
+func (d *decoder) decodeList() ([]interface{}, error) {
+	if err := d.expect('l'); err != nil {
+		return nil, err
+	}
+	var list []interface{}
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 'e' {
+			d.pos++
+			return list, nil
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+}
+
+func (d *decoder) decodeDict() (map[string]interface{}, error) {
+	if err := d.expect('d'); err != nil {
+		return nil, err
+	}
+	dict := make(map[string]interface{})
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 'e' {
+			d.pos++
+			return dict, nil
+		}
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, err
+		}
+		if dict[key], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package torrent
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoHash(t *testing.T) {
+	t.Run("fedora torrent", func(t *testing.T) {
+		b, err := ioutil.ReadFile("../rtorrent/testdata/Fedora-i3-Live-x86_64-35.torrent")
+		require.NoError(t, err)
+
+		hash, err := InfoHash(b)
+		require.NoError(t, err)
+		require.Equal(t, "299939CFF841ED7FFCA2B3C2A35711C12589632B", hash)
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		for _, data := range []string{"", "not bencode", "d4:info", "d4:infoi1ee", "d3:foo3:bare", "d4:infod4:name99:xee"} {
+			_, err := InfoHash([]byte(data))
+			require.Error(t, err, data)
+			require.Equal(t, ErrInvalid, errors.Cause(err), data)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("multi-file", func(t *testing.T) {
+		b, err := ioutil.ReadFile("../rtorrent/testdata/Fedora-i3-Live-x86_64-35.torrent")
+		require.NoError(t, err)
+
+		m, err := Parse(b)
+		require.NoError(t, err)
+		require.Equal(t, &Metainfo{
+			Hash: "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+			Name: "Fedora-i3-Live-x86_64-35",
+			Size: 1437206706,
+			Files: []File{
+				{Path: "Fedora-Spins-35-1.2-x86_64-CHECKSUM", Size: 2226},
+				{Path: "Fedora-i3-Live-x86_64-35-1.2.iso", Size: 1437204480},
+			},
+		}, m)
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		data := "d8:announce3:url7:unknownli1ee4:infod6:lengthi5000000000e4:name8:file.iso12:piece lengthi262144e6:pieces0:ee"
+		m, err := Parse([]byte(data))
+		require.NoError(t, err)
+		hash, err := InfoHash([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, &Metainfo{Hash: hash, Name: "file.iso", Size: 5000000000,
+			Files: []File{{Path: "file.iso", Size: 5000000000}}}, m)
+	})
+
+	t.Run("nested path", func(t *testing.T) {
+		m, err := Parse([]byte("d4:infod5:filesld6:lengthi1e4:pathl3:sub5:a.txteed6:lengthi2e4:pathl5:b.txteee4:name3:diree"))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), m.Size)
+		require.Equal(t, []File{{Path: "sub/a.txt", Size: 1}, {Path: "b.txt", Size: 2}}, m.Files)
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		for _, data := range []string{
+			"d4:infoi1ee",
+			"d4:infod6:lengthi1eee",
+			"d4:infod4:name1:aee",
+			"d4:infod5:filesli1ee4:name1:aee",
+			"d4:infod5:filesld4:pathl1:xeee4:name1:aee",
+			"d4:infod5:filesld6:lengthi1e4:pathleee4:name1:aee",
+		} {
+			_, err := Parse([]byte(data))
+			require.Error(t, err, data)
+			require.Equal(t, ErrInvalid, errors.Cause(err), data)
+		}
+	})
+}
+
+func TestMagnetInfoHash(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"magnet:?xt=urn:btih:299939cff841ed7ffca2b3c2a35711c12589632b&dn=Fedora-i3-Live-x86_64-35": "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		"magnet:?dn=Fedora&xt=URN:BTIH:299939CFF841ED7FFCA2B3C2A35711C12589632B":                   "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+		"magnet:?xt=urn:btih:fgmttt7yihwx77fcwpbkgvyryesysyzl":                                     "299939CFF841ED7FFCA2B3C2A35711C12589632B",
+	} {
+		hash, err := MagnetInfoHash(uri)
+		require.NoError(t, err, uri)
+		require.Equal(t, expected, hash, uri)
+	}
+
+	for _, uri := range []string{
+		"http://example.com/file.torrent",
+		"magnet:?dn=no+hash",
+		"magnet:?xt=urn:btih:not-a-hash",
+		"magnet:?xt=urn:btih:299939cff841ed7ffca2b3c2a35711c12589632z",
+	} {
+		_, err := MagnetInfoHash(uri)
+		require.Error(t, err, uri)
+	}
+}
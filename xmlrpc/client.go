@@ -2,8 +2,16 @@ package xmlrpc
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -12,16 +20,26 @@ import (
 type Client struct {
 	addr       string
 	httpClient *http.Client
+	headers    http.Header
+	dialect    IntDialect
+	// username and password are sent with every request when username is set, see WithBasicAuth
+	username string
+	password string
 }
 
+// defaultMaxIdleConns is the number of idle connections to rTorrent kept for reuse by NewClient, http.Transport only
+// keeps 2 per host by default, which is too few for concurrent pollers
+const defaultMaxIdleConns = 16
+
 // NewClient returns a new instance of Client
 // Pass in a true value for `insecure` to turn off certificate verification
 func NewClient(addr string, insecure bool) *Client {
-	transport := &http.Transport{}
+	transport := &http.Transport{
+		MaxIdleConns:        defaultMaxIdleConns,
+		MaxIdleConnsPerHost: defaultMaxIdleConns,
+	}
 	if insecure {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
 	httpClient := &http.Client{Transport: transport}
@@ -29,6 +47,7 @@ func NewClient(addr string, insecure bool) *Client {
 	return &Client{
 		addr:       addr,
 		httpClient: httpClient,
+		headers:    make(http.Header),
 	}
 }
 
@@ -38,25 +57,383 @@ func NewClientWithHTTPClient(addr string, client *http.Client) *Client {
 	return &Client{
 		addr:       addr,
 		httpClient: client,
+		headers:    make(http.Header),
+	}
+}
+
+// WithHTTPClient replaces the http.Client used for requests, keeping the other settings of the Client
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	c.httpClient = client
+	return c
+}
+
+// WithTimeout sets the time limit of requests, including reading the response, 0 means no limit
+// The timeout is set on a copy of the http.Client, so a client passed to NewClientWithHTTPClient is left untouched.
+// WithHTTPClient replaces the http.Client and so the timeout, call WithTimeout after it to apply both.
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	httpClient := *c.httpClient
+	httpClient.Timeout = timeout
+	c.httpClient = &httpClient
+	return c
+}
+
+// WithHeader adds a header which is sent with every request
+// It can be called repeatedly, values for the same key are accumulated. A Host header replaces the host sent in the
+// request, which otherwise is the one of the endpoint.
+func (c *Client) WithHeader(key, value string) *Client {
+	c.headers.Add(key, value)
+	return c
+}
+
+// WithBasicAuth sets the credentials sent with every request using HTTP basic authentication
+// They are kept on the Client rather than the http.Client, so they apply whichever http.Client is used.
+func (c *Client) WithBasicAuth(username, password string) *Client {
+	c.username, c.password = username, password
+	return c
+}
+
+// WithIntDialect sets the tag used for integers in requests, see IntDialect
+func (c *Client) WithIntDialect(dialect IntDialect) *Client {
+	c.dialect = dialect
+	return c
+}
+
+// WithHTTP1Only makes the Client only use HTTP/1.1, by disabling HTTP/2 on a copy of its transport
+// Use it when a proxy in front of rTorrent mishandles HTTP/2, e.g. when requests intermittently fail with stream
+// errors. The other settings of the http.Client and its transport, like Timeout and the TLS configuration, are kept.
+// It has no effect when the transport of the http.Client isn't a *http.Transport.
+func (c *Client) WithHTTP1Only() *Client {
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return c
+	}
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	if transport.TLSClientConfig != nil {
+		transport.TLSClientConfig.NextProtos = nil
 	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	return c
+}
+
+// TransportOptions tune the pooling of the connections of a Client, see WithTransportOptions
+// Zero values leave the current setting unchanged.
+type TransportOptions struct {
+	// MaxIdleConns is the number of idle connections kept for reuse, across all hosts
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the number of idle connections kept for reuse to a single host, rTorrent usually
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before being closed
+	IdleConnTimeout time.Duration
+}
+
+// WithTransportOptions applies the options to a copy of the transport of the http.Client, like WithHTTP1Only
+// NewClient keeps up to 16 idle connections to rTorrent, raise it when more calls are made concurrently.
+// It has no effect when the transport of the http.Client isn't a *http.Transport.
+func (c *Client) WithTransportOptions(options TransportOptions) *Client {
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return c
+	}
+	if options.MaxIdleConns > 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+	}
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	return c
+}
+
+// maxDrain is how much of an unread response body is read before closing it, larger bodies cost more to read than
+// opening a new connection
+const maxDrain = 64 << 10
+
+// drainAndClose reads what is left of the body before closing it, so the connection can be reused
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(body, maxDrain))
+	body.Close()
 }
 
 // Call calls the method with "name" with the given args
-// Returns the result, and an error for communication errors
+// Returns the result, and an error for communication errors. When the server answers with a fault, the error is the
+// Fault, which can be inspected with errors.As even once wrapped.
 func (c *Client) Call(name string, args ...interface{}) (interface{}, error) {
-	req := bytes.NewBuffer(nil)
-	if err := Marshal(req, name, args...); err != nil {
-		return nil, errors.Wrap(err, "failed to marshal request")
+	return c.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, the request is aborted when ctx is done
+// The error returned for an aborted request wraps ctx.Err(), so it can be checked with errors.Is.
+func (c *Client) CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	httpReq, err := c.newRequest(ctx, name, args...)
+	if err != nil {
+		return nil, err
 	}
-	resp, err := c.httpClient.Post(c.addr, "text/xml", req)
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, errors.Wrap(err, "POST failed")
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
+	if err := statusError(resp); err != nil {
+		return nil, err
+	}
 
 	_, val, fault, err := Unmarshal(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if fault != nil {
+		return nil, *fault
+	}
+	return val, nil
+}
+
+// maxErrorBody is how much of the body of a non-2xx response is included in the error
+const maxErrorBody = 256
+
+// statusError returns an error for a non-2xx response, with the start of its body, which usually tells what is wrong
+// when a proxy answers instead of rTorrent, e.g. an authentication or gateway error page.
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	truncated := len(body) > maxErrorBody
+	if truncated {
+		body = body[:maxErrorBody]
+	}
+	snippet := strings.Join(strings.Fields(string(body)), " ")
+	if truncated {
+		snippet += "..."
+	}
+	if snippet == "" {
+		return errors.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+	return errors.Errorf("unexpected HTTP status: %s: %s", resp.Status, snippet)
+}
+
+// Call is a single method call of a MultiCall
+type Call struct {
+	Name string
+	Args []interface{}
+}
+
+// MultiCall calls the methods in a single system.multicall request and returns their results in order
+// The result of each call is its return value, or a Fault when that call failed, which doesn't affect the others.
+// The error is for communication errors, and responses which don't match the calls.
+func (c *Client) MultiCall(calls []Call) ([]interface{}, error) {
+	return c.MultiCallContext(context.Background(), calls)
+}
+
+// MultiCallContext is like MultiCall, the request is aborted when ctx is done
+func (c *Client) MultiCallContext(ctx context.Context, calls []Call) ([]interface{}, error) {
+	return MultiCallWith(ctx, c.CallContext, calls)
+}
+
+// CallFunc makes a single XMLRPC call, like Client.CallContext
+type CallFunc func(ctx context.Context, name string, args ...interface{}) (interface{}, error)
+
+// MultiCallWith is like MultiCallContext, making the system.multicall request with call
+// It allows batching calls over another implementation than Client, e.g. a fake one in tests.
+func MultiCallWith(ctx context.Context, call CallFunc, calls []Call) ([]interface{}, error) {
+	batch := make([]interface{}, 0, len(calls))
+	for _, c := range calls {
+		args := c.Args
+		if args == nil {
+			args = []interface{}{}
+		}
+		batch = append(batch, map[string]interface{}{"methodName": c.Name, "params": args})
+	}
+	result, err := call(ctx, "system.multicall", batch)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	if params, ok := result.([]interface{}); ok && len(params) > 0 {
+		values, _ = params[0].([]interface{})
+	}
+	if len(values) != len(calls) {
+		return nil, errors.Errorf("system.multicall returned %d results for %d calls", len(values), len(calls))
+	}
+	results := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case []interface{}:
+			// successful calls are wrapped in a single element array
+			if len(v) > 0 {
+				results[i] = v[0]
+			}
+		case map[string]interface{}:
+			var code int
+			switch c := v["faultCode"].(type) {
+			case int:
+				code = c
+			case int64:
+				code = int(c)
+			}
+			message, _ := v["faultString"].(string)
+			results[i] = Fault{Code: code, Message: message}
+		default:
+			return nil, errors.Errorf("unexpected system.multicall result for %s: %v", calls[i].Name, value)
+		}
+	}
+	return results, nil
+}
+
+// newRequest returns the HTTP request calling the method with "name" with the given args
+func (c *Client) newRequest(ctx context.Context, name string, args ...interface{}) (*http.Request, error) {
+	req := bytes.NewBuffer(nil)
+	if err := MarshalDialect(req, c.dialect, name, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	for key, values := range c.headers {
+		if key == "Host" {
+			// net/http ignores the Host header in favour of Request.Host
+			httpReq.Host = values[len(values)-1]
+			continue
+		}
+		for _, value := range values {
+			httpReq.Header.Add(key, value)
+		}
+	}
+	if c.username != "" {
+		httpReq.SetBasicAuth(c.username, c.password)
+	}
+	httpReq.Header.Set("Content-Type", "text/xml")
+	return httpReq, nil
+}
+
+// DiagnosticStage is a step of reaching the XMLRPC endpoint, see Diagnose
+type DiagnosticStage string
+
+const (
+	// StageDNS is resolving the host of the endpoint
+	StageDNS DiagnosticStage = "dns"
+	// StageTCP is connecting to the host and port of the endpoint
+	StageTCP DiagnosticStage = "tcp"
+	// StageTLS is the TLS handshake with endpoints using https
+	StageTLS DiagnosticStage = "tls"
+	// StageHTTP is sending the request and receiving a response with the 200 OK status
+	StageHTTP DiagnosticStage = "http"
+	// StageXMLRPC is reading a valid XMLRPC response without a fault
+	StageXMLRPC DiagnosticStage = "xmlrpc"
+)
+
+// Diagnostics is the result of Diagnose
+type Diagnostics struct {
+	// FailedStage is the stage which failed, empty when all of them passed
+	FailedStage DiagnosticStage
+	// Err is why FailedStage failed
+	Err error
+	// Addresses are the IP addresses the host of the endpoint resolved to
+	Addresses []string
+	// StatusCode is the HTTP status code of the response, 0 when none was received
+	StatusCode int
+}
+
+// diagnoseTimeout bounds each of the stages of Diagnose
+const diagnoseTimeout = 5 * time.Second
+
+// Diagnose checks each stage of calling the method with "name" with the given args, stopping at the first failure
+// A method without side effects should be used. The returned error is only about an endpoint which can't be parsed,
+// failures of the stages are reported in the Diagnostics. The DNS and TCP stages are checked directly, so they
+// don't go through a proxy the http.Client may use.
+func (c *Client) Diagnose(name string, args ...interface{}) (Diagnostics, error) {
+	var d Diagnostics
+	fail := func(stage DiagnosticStage, err error) (Diagnostics, error) {
+		d.FailedStage, d.Err = stage, err
+		return d, nil
+	}
+
+	u, err := url.Parse(c.addr)
+	if err != nil {
+		return d, errors.Wrap(err, "invalid endpoint")
+	}
+	port := u.Port()
+	switch {
+	case port != "":
+	case u.Scheme == "http":
+		port = "80"
+	case u.Scheme == "https":
+		port = "443"
+	default:
+		return d, errors.Errorf("invalid endpoint %q: the scheme must be http or https", c.addr)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return d, errors.Errorf("invalid endpoint %q: missing host", c.addr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+	if net.ParseIP(host) != nil {
+		d.Addresses = []string{host}
+	} else if d.Addresses, err = net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return fail(StageDNS, err)
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), diagnoseTimeout)
+	if err != nil {
+		return fail(StageTCP, err)
+	}
+	conn.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+	req, err := c.newRequest(ctx, name, args...)
+	if err != nil {
+		return d, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if isTLSError(err) {
+			return fail(StageTLS, err)
+		}
+		return fail(StageHTTP, err)
+	}
+	defer drainAndClose(resp.Body)
+	d.StatusCode = resp.StatusCode
+	if err := statusError(resp); err != nil {
+		return fail(StageHTTP, err)
+	}
+
+	_, _, fault, err := Unmarshal(resp.Body)
+	if err != nil {
+		return fail(StageXMLRPC, errors.Wrap(err, "invalid XMLRPC response"))
+	}
 	if fault != nil {
-		err = errors.Errorf("Error: %v: %v", err, fault)
+		return fail(StageXMLRPC, *fault)
 	}
-	return val, err
+	return d, nil
+}
+
+// isTLSError checks if the error happened during the TLS handshake
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || strings.Contains(err.Error(), "tls: ")
 }
@@ -1,3 +1,376 @@
 package xmlrpc
 
-// TODO: add tests
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallContext(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(received)
+		// hang like an unresponsive rTorrent until the test is over
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := NewClient(server.URL, false).CallContext(ctx, "system.client_version")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled), err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatal("call was not aborted when the context was cancelled")
+	}
+}
+
+func TestCallFault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.NoError(t, Marshal(w, "", Fault{Code: -501, Message: "Could not find info-hash."}))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, false).Call("d.name", "ABC")
+	require.Error(t, err)
+	var fault Fault
+	require.True(t, errors.As(errors.Wrap(err, "d.name XMLRPC call failed"), &fault), err.Error())
+	require.Equal(t, -501, fault.Code)
+	require.Equal(t, "Could not find info-hash.", fault.Message)
+	require.Equal(t, "-501: Could not find info-hash.", err.Error())
+}
+
+func TestCallHTTPStatus(t *testing.T) {
+	status, body := http.StatusBadGateway, "<html>\n<head><title>502 Bad Gateway</title></head>\n</html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, false)
+
+	_, err := client.Call("system.client_version")
+	require.Error(t, err)
+	require.Equal(t, "unexpected HTTP status: 502 Bad Gateway: <html> <head><title>502 Bad Gateway</title></head> </html>", err.Error())
+
+	status, body = http.StatusUnauthorized, strings.Repeat("a", 1000)
+	_, err = client.Call("system.client_version")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "401 Unauthorized")
+	require.True(t, strings.HasSuffix(err.Error(), strings.Repeat("a", maxErrorBody)+"..."), err.Error())
+
+	status, body = http.StatusNotFound, ""
+	_, err = client.Call("system.client_version")
+	require.EqualError(t, err, "unexpected HTTP status: 404 Not Found")
+}
+
+func TestMultiCall(t *testing.T) {
+	torrents := map[string]map[string]interface{}{
+		"ABC": {"d.name": "Fedora-i3-Live-x86_64-35", "d.size_bytes": 1437206706},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, args, _, err := Unmarshal(req.Body)
+		require.NoError(t, err)
+		require.Equal(t, "system.multicall", name)
+		var results []interface{}
+		for _, c := range args[0].([]interface{}) {
+			c := c.(map[string]interface{})
+			method, params := c["methodName"].(string), c["params"].([]interface{})
+			value, ok := torrents[params[0].(string)][method]
+			if !ok {
+				results = append(results, map[string]interface{}{"faultCode": -501, "faultString": "Could not find info-hash."})
+				continue
+			}
+			results = append(results, []interface{}{value})
+		}
+		require.NoError(t, Marshal(w, "", results))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, false)
+
+	t.Run("results", func(t *testing.T) {
+		results, err := client.MultiCall([]Call{
+			{Name: "d.name", Args: []interface{}{"ABC"}},
+			{Name: "d.size_bytes", Args: []interface{}{"ABC"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"Fedora-i3-Live-x86_64-35", 1437206706}, results)
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		results, err := client.MultiCall([]Call{
+			{Name: "d.name", Args: []interface{}{"MISSING"}},
+			{Name: "d.name", Args: []interface{}{"ABC"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{Fault{Code: -501, Message: "Could not find info-hash."}, "Fedora-i3-Live-x86_64-35"}, results)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		results, err := client.MultiCall(nil)
+		require.NoError(t, err)
+		require.Empty(t, results)
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	httpClient := &http.Client{}
+	client := NewClientWithHTTPClient(server.URL, httpClient).WithTimeout(time.Millisecond)
+	start := time.Now()
+	_, err := client.Call("system.client_version")
+	require.Error(t, err)
+	require.True(t, time.Since(start) < time.Second, "took %v", time.Since(start))
+	require.Zero(t, httpClient.Timeout)
+}
+
+func TestWithHeader(t *testing.T) {
+	var header http.Header
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header, host = req.Header, req.Host
+		require.NoError(t, Marshal(w, "", "ok"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, false).
+		WithHeader("X-Proxy-Secret", "secret").
+		WithHeader("X-Forwarded-For", "10.0.0.1").
+		WithHeader("X-Forwarded-For", "10.0.0.2")
+	_, err := client.Call("test")
+	require.NoError(t, err)
+	require.Equal(t, "secret", header.Get("X-Proxy-Secret"))
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, header.Values("X-Forwarded-For"))
+	require.Equal(t, "text/xml", header.Get("Content-Type"))
+	require.Equal(t, server.Listener.Addr().String(), host)
+
+	_, err = client.WithHeader("Host", "rtorrent.example").Call("test")
+	require.NoError(t, err)
+	require.Equal(t, "rtorrent.example", host)
+	require.Equal(t, "secret", header.Get("X-Proxy-Secret"))
+}
+
+func TestWithBasicAuth(t *testing.T) {
+	var user, pass string
+	var ok bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, pass, ok = req.BasicAuth()
+		require.NoError(t, Marshal(w, "", "ok"))
+	}))
+	defer server.Close()
+
+	t.Run("insecure", func(t *testing.T) {
+		_, err := NewClient(server.URL, true).WithBasicAuth("user", "p@ss:word").Call("test")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "p@ss:word", pass)
+	})
+
+	t.Run("custom http client", func(t *testing.T) {
+		_, err := NewClient(server.URL, false).WithBasicAuth("other", "secret").WithHTTPClient(server.Client()).Call("test")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "other", user)
+		require.Equal(t, "secret", pass)
+	})
+
+	t.Run("not set", func(t *testing.T) {
+		_, err := NewClientWithHTTPClient(server.URL, server.Client()).Call("test")
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, _, _, err := Unmarshal(req.Body)
+		require.NoError(t, err)
+		switch name {
+		case "fault":
+			require.NoError(t, Marshal(w, "", Fault{Code: -501, Message: "Could not find info-hash."}))
+		case "padded":
+			require.NoError(t, Marshal(w, "", "ok"))
+			// left unread by Unmarshal
+			_, _ = w.Write([]byte(strings.Repeat(" ", 32<<10)))
+		case "status":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(strings.Repeat("error ", 5000)))
+		default:
+			require.NoError(t, Marshal(w, "", "ok"))
+		}
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := NewClient(server.URL, false)
+	for _, name := range []string{"ok", "fault", "padded", "status", "ok", "fault", "padded", "status"} {
+		_, _ = client.Call(name)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 1, conns, "expected the connection to be reused for every call")
+
+	t.Run("transport options", func(t *testing.T) {
+		client := NewClient(server.URL, true).WithTransportOptions(TransportOptions{MaxIdleConnsPerHost: 64})
+		transport := client.httpClient.Transport.(*http.Transport)
+		require.Equal(t, 64, transport.MaxIdleConnsPerHost)
+		require.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns, "zero values are left unchanged")
+		require.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+	})
+}
+
+func TestWithHTTP1Only(t *testing.T) {
+	var proto string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proto = req.Proto
+		require.NoError(t, Marshal(w, "", "ok"))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	t.Run("http2 by default", func(t *testing.T) {
+		_, err := NewClientWithHTTPClient(server.URL, server.Client()).Call("test")
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/2.0", proto)
+	})
+
+	t.Run("http1 only", func(t *testing.T) {
+		httpClient := server.Client()
+		httpClient.Timeout = time.Minute
+		client := NewClientWithHTTPClient(server.URL, httpClient).WithHTTP1Only()
+		_, err := client.Call("test")
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/1.1", proto)
+		require.Equal(t, time.Minute, client.httpClient.Timeout)
+
+		// the original http.Client is left untouched
+		_, err = NewClientWithHTTPClient(server.URL, httpClient).Call("test")
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/2.0", proto)
+	})
+
+	t.Run("insecure kept", func(t *testing.T) {
+		_, err := NewClient(server.URL, true).WithHTTP1Only().Call("test")
+		require.NoError(t, err)
+		require.Equal(t, "HTTP/1.1", proto)
+	})
+}
+
+func TestDiagnose(t *testing.T) {
+	newServer := func(handler http.HandlerFunc) *httptest.Server {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		server := newServer(func(w http.ResponseWriter, req *http.Request) {
+			require.NoError(t, Marshal(w, "", "0.9.8"))
+		})
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Empty(t, d.FailedStage)
+		require.NoError(t, d.Err)
+		require.Equal(t, []string{"127.0.0.1"}, d.Addresses)
+		require.Equal(t, http.StatusOK, d.StatusCode)
+	})
+
+	t.Run("dns", func(t *testing.T) {
+		d, err := NewClient("http://rtorrent.invalid/RPC2", false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageDNS, d.FailedStage)
+		require.Error(t, d.Err)
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageTCP, d.FailedStage)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.NotFoundHandler())
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageTLS, d.FailedStage)
+	})
+
+	t.Run("http status", func(t *testing.T) {
+		server := newServer(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageHTTP, d.FailedStage)
+		require.Equal(t, http.StatusUnauthorized, d.StatusCode)
+	})
+
+	t.Run("not xmlrpc", func(t *testing.T) {
+		server := newServer(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte("<html><body>ruTorrent</body></html>"))
+		})
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageXMLRPC, d.FailedStage)
+	})
+
+	t.Run("fault", func(t *testing.T) {
+		server := newServer(func(w http.ResponseWriter, req *http.Request) {
+			require.NoError(t, Marshal(w, "", Fault{Code: -506, Message: "Method 'system.client_version' not defined"}))
+		})
+		d, err := NewClient(server.URL, false).Diagnose("system.client_version")
+		require.NoError(t, err)
+		require.Equal(t, StageXMLRPC, d.FailedStage)
+		require.Equal(t, Fault{Code: -506, Message: "Method 'system.client_version' not defined"}, d.Err)
+	})
+
+	t.Run("invalid endpoint", func(t *testing.T) {
+		for _, addr := range []string{"rtorrent:5000", "scp://rtorrent/RPC2", "http:///RPC2", "%"} {
+			_, err := NewClient(addr, false).Diagnose("system.client_version")
+			require.Error(t, err, addr)
+		}
+	})
+}
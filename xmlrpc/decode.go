@@ -0,0 +1,184 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Decode populates target, which must be a non-nil pointer, from a value returned by Call or Unmarshal
+// It is the reverse of WriteXML:
+//  - structs are decoded from XMLRPC structs, matching members by the xml tag of the fields or their name.
+//    They can also be decoded from arrays, assigning the elements to the exported fields in order, which suits
+//    the rows returned by d.multicall2 and similar commands.
+//  - slices and arrays are decoded from XMLRPC arrays, maps with string keys from XMLRPC structs
+//  - integers are converted to any integer, float or bool field (rTorrent returns 0 or 1 for booleans)
+// Fields without a value, including struct members which are missing, are left at their zero value.
+func Decode(value interface{}, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("decode target must be a non-nil pointer, got %T", target)
+	}
+	return decodeValue(value, rv.Elem())
+}
+
+func decodeValue(v interface{}, rv reflect.Value) error {
+	if v == nil {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(v, rv.Elem())
+	case reflect.Interface:
+		value := reflect.ValueOf(v)
+		if !value.Type().AssignableTo(rv.Type()) {
+			return errors.Errorf("cannot decode %T into %s", v, rv.Type())
+		}
+		rv.Set(value)
+		return nil
+	}
+	if rv.Type() == timeType {
+		t, ok := v.(time.Time)
+		if !ok {
+			return errors.Errorf("cannot decode %T into %s", v, rv.Type())
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch value := v.(type) {
+	case bool:
+		if rv.Kind() == reflect.Bool {
+			rv.SetBool(value)
+			return nil
+		}
+	case int:
+		return decodeInt(int64(value), rv)
+	case int64:
+		return decodeInt(value, rv)
+	case float64:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(value)
+			return nil
+		}
+	case string:
+		if rv.Kind() == reflect.String {
+			rv.SetString(value)
+			return nil
+		}
+	case []byte:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes(value)
+			return nil
+		}
+	case []interface{}:
+		return decodeArray(value, rv)
+	case map[string]interface{}:
+		return decodeStruct(value, rv)
+	}
+	return errors.Errorf("cannot decode %T into %s", v, rv.Type())
+}
+
+func decodeInt(i int64, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.OverflowInt(i) {
+			return errors.Errorf("%d overflows %s", i, rv.Type())
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i < 0 || rv.OverflowUint(uint64(i)) {
+			return errors.Errorf("%d overflows %s", i, rv.Type())
+		}
+		rv.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		rv.SetFloat(float64(i))
+	case reflect.Bool:
+		rv.SetBool(i != 0)
+	default:
+		return errors.Errorf("cannot decode %T into %s", i, rv.Type())
+	}
+	return nil
+}
+
+func decodeArray(values []interface{}, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(rv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := decodeValue(v, slice.Index(i)); err != nil {
+				return errors.Wrapf(err, "index %d", i)
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(values) > rv.Len() {
+			return errors.Errorf("cannot decode %d values into %s", len(values), rv.Type())
+		}
+		for i, v := range values {
+			if err := decodeValue(v, rv.Index(i)); err != nil {
+				return errors.Wrapf(err, "index %d", i)
+			}
+		}
+		return nil
+	case reflect.Struct:
+		fields := exportedFields(rv.Type())
+		if len(values) > len(fields) {
+			return errors.Errorf("cannot decode %d values into %s", len(values), rv.Type())
+		}
+		for i, v := range values {
+			if err := decodeValue(v, rv.Field(fields[i])); err != nil {
+				return errors.Wrapf(err, "field %s", rv.Type().Field(fields[i]).Name)
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("cannot decode %T into %s", values, rv.Type())
+}
+
+func decodeStruct(members map[string]interface{}, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(members))
+		for name, v := range members {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeValue(v, elem); err != nil {
+				return errors.Wrapf(err, "member %s", name)
+			}
+			m.SetMapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+		return nil
+	case reflect.Struct:
+		for _, i := range exportedFields(rv.Type()) {
+			name := getStructFieldName(rv.Type().Field(i))
+			if err := decodeValue(members[name], rv.Field(i)); err != nil {
+				return errors.Wrapf(err, "member %s", name)
+			}
+		}
+		return nil
+	}
+	return errors.Errorf("cannot decode %T into %s", members, rv.Type())
+}
+
+// exportedFields returns the indexes of the exported fields of the struct type, which WriteXML writes
+func exportedFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type decodeTracker struct {
+	URL     string `xml:"url"`
+	Enabled bool   `xml:"enabled"`
+}
+
+type decodeTorrent struct {
+	Hash     string `xml:"hash"`
+	Size     int64  `xml:"size"`
+	Ratio    float64
+	Complete bool
+	Created  time.Time
+	Trackers []decodeTracker `xml:"trackers"`
+	Peers    *int
+	Extra    map[string]interface{}
+	private  string
+}
+
+func TestDecode(t *testing.T) {
+	created := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("nested struct", func(t *testing.T) {
+		var torrent decodeTorrent
+		require.NoError(t, Decode(map[string]interface{}{
+			"hash":     "ABC",
+			"size":     int64(5000000000),
+			"Ratio":    1.5,
+			"Complete": 1,
+			"Created":  created,
+			"Trackers": nil,
+			"trackers": []interface{}{
+				map[string]interface{}{"url": "http://tracker.example/announce", "enabled": 1},
+				map[string]interface{}{"url": "dht://", "enabled": false},
+			},
+			"Peers":   12,
+			"Extra":   map[string]interface{}{"a": "b"},
+			"private": "ignored",
+		}, &torrent))
+		peers := 12
+		require.Equal(t, decodeTorrent{
+			Hash:     "ABC",
+			Size:     5000000000,
+			Ratio:    1.5,
+			Complete: true,
+			Created:  created,
+			Trackers: []decodeTracker{{"http://tracker.example/announce", true}, {"dht://", false}},
+			Peers:    &peers,
+			Extra:    map[string]interface{}{"a": "b"},
+		}, torrent)
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		var torrent decodeTorrent
+		require.NoError(t, Decode(map[string]interface{}{"hash": "ABC"}, &torrent))
+		require.Equal(t, decodeTorrent{Hash: "ABC"}, torrent)
+	})
+
+	t.Run("rows", func(t *testing.T) {
+		var rows []decodeTracker
+		require.NoError(t, Decode([]interface{}{
+			[]interface{}{"http://tracker.example/announce", 1},
+			[]interface{}{"udp://tracker.example:1337"},
+		}, &rows))
+		require.Equal(t, []decodeTracker{{"http://tracker.example/announce", true}, {"udp://tracker.example:1337", false}}, rows)
+	})
+
+	t.Run("arrays", func(t *testing.T) {
+		var sizes [3]uint16
+		require.NoError(t, Decode([]interface{}{1, 2}, &sizes))
+		require.Equal(t, [3]uint16{1, 2, 0}, sizes)
+
+		var values []interface{}
+		require.NoError(t, Decode([]interface{}{"a", 1}, &values))
+		require.Equal(t, []interface{}{"a", 1}, values)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		type roundTrip struct {
+			Hash     string `xml:"hash"`
+			Size     int
+			Ratio    float64
+			Complete bool
+			Created  time.Time
+			Trackers []decodeTracker
+		}
+		// the time is formatted without a zone and parsed back in the local one
+		in := roundTrip{Hash: "ABC", Size: 42, Ratio: 0.5, Complete: true, Created: time.Date(2021, 11, 1, 12, 0, 0, 0, time.Local),
+			Trackers: []decodeTracker{{"http://tracker.example/announce", true}}}
+		var buf bytes.Buffer
+		require.NoError(t, Marshal(&buf, "", in))
+		_, params, _, err := Unmarshal(&buf)
+		require.NoError(t, err)
+
+		var out roundTrip
+		require.NoError(t, Decode(params[0], &out))
+		require.Equal(t, in, out)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var torrent decodeTorrent
+		require.Error(t, Decode(map[string]interface{}{}, torrent), "not a pointer")
+		require.Error(t, Decode(map[string]interface{}{}, (*decodeTorrent)(nil)), "nil pointer")
+		require.Error(t, Decode(map[string]interface{}{"hash": 1}, &torrent), "int into string")
+		require.Error(t, Decode(map[string]interface{}{"size": "1"}, &torrent), "string into int")
+		require.Error(t, Decode("row", &[]string{}), "string into slice")
+		require.Error(t, Decode([]interface{}{"a", 1, 2}, &decodeTracker{}), "too many columns")
+
+		var small int8
+		require.Error(t, Decode(300, &small), "overflow")
+		var unsigned uint
+		require.Error(t, Decode(-1, &unsigned), "negative into unsigned")
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -25,10 +26,52 @@ const (
 	DummyXMLRpcTime = "20060102T15:04:05-0700"
 )
 
+// IntDialect controls the tag used when marshalling integers
+//
+// The XML-RPC specification only knows 32-bit integers tagged with <int> or its alias <i4>. rTorrent (like most
+// servers built on xmlrpc-c) also accepts larger values, either in an <int> or in the <i8> extension tag. Strict
+// servers reject both, while some servers only accept one of the aliases.
+type IntDialect int
+
+const (
+	// IntDialectInt tags all integers with <int>, this is the default and what rTorrent expects
+	IntDialectInt IntDialect = iota
+	// IntDialectI4 tags all integers with <i4>, for servers which don't understand <int>
+	// Marshalling an integer outside of the 32-bit range fails, as <i4> can't hold it.
+	IntDialectI4
+	// IntDialectI8 tags integers outside of the 32-bit range with the <i8> extension and all others with <int>
+	IntDialectI8
+)
+
+func (d IntDialect) tag(r reflect.Value) (string, error) {
+	switch d {
+	case IntDialectI4:
+		if !fitsInt32(r) {
+			return "", fmt.Errorf("%v overflows <i4>", r.Interface())
+		}
+		return "i4", nil
+	case IntDialectI8:
+		if !fitsInt32(r) {
+			return "i8", nil
+		}
+	}
+	return "int", nil
+}
+
+// fitsInt32 checks if the integer r is within the 32-bit range of <int> and <i4>
+func fitsInt32(r reflect.Value) bool {
+	switch r.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := r.Int()
+		return i >= math.MinInt32 && i <= math.MaxInt32
+	}
+	return r.Uint() <= math.MaxInt32
+}
+
 // ErrUnsupported is the error of "Unsupported type"
 var ErrUnsupported = errors.New("Unsupported type")
 
-// Fault is the struct for the fault response
+// Fault is the struct for the fault response, and the error returned by Client.Call for it
 type Fault struct {
 	Code    int
 	Message string
@@ -55,6 +98,8 @@ var xmlSpecial = map[byte]string{
 	'"':  "&quot;",
 	'\'': "&apos;",
 	'&':  "&amp;",
+	// XML parsers normalize line endings, a literal \r would be read back as \n
+	'\r': "&#xD;",
 }
 
 func xmlEscape(s string) string {
@@ -126,13 +171,16 @@ func (st *state) parseValue() (nv interface{}, e error) {
 		case "string":
 			nv = vn.Body
 		case "int", "i1", "i2", "i4":
-			var i64 int64
-			i64, e = strconv.ParseInt(vn.Body, 10, 32)
-			nv = int(i64)
-		case "i8":
 			var i64 int64
 			i64, e = strconv.ParseInt(vn.Body, 10, 64)
 			nv = int(i64)
+			// some servers send 64-bit values in <int>, keep them whole as for <i8>
+			if i64 < math.MinInt32 || i64 > math.MaxInt32 {
+				nv = i64
+			}
+		case "i8":
+			// i8 values are kept as int64, so they aren't truncated where int is 32-bit
+			nv, e = strconv.ParseInt(vn.Body, 10, 64)
 		case "double":
 			nv, e = strconv.ParseFloat(vn.Body, 64)
 		case "dateTime.iso8601":
@@ -204,6 +252,11 @@ func (st *state) parseValue() (nv interface{}, e error) {
 		}
 		nv = values
 		return
+	case "nil":
+		// the nil extension, <nil/> or <ex:nil/>, is returned as a Go nil
+		st.last = nil
+		e = st.p.Skip()
+		return
 	default:
 		e = fmt.Errorf("cannot parse unknown tag %s", se)
 	}
@@ -377,6 +430,10 @@ func toXML(v interface{}, typ bool) (s string) {
 
 // WriteXML writes v, typed if typ is true, into w Writer
 func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
+	return writeXML(w, v, typ, IntDialectInt)
+}
+
+func writeXML(w io.Writer, v interface{}, typ bool, dialect IntDialect) (err error) {
 	var (
 		r  reflect.Value
 		ok bool
@@ -416,7 +473,11 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 		reflect.Uint,
 		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		if typ {
-			_, err = fmt.Fprintf(w, "<int>%v</int>", v)
+			tag, err := dialect.tag(r)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "<%s>%v</%s>", tag, v, tag)
 			return err
 		}
 		_, err = fmt.Fprintf(w, "%v", v)
@@ -437,7 +498,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 			if _, err = io.WriteString(w, "  <value>"); err != nil {
 				return
 			}
-			if err = WriteXML(w, r.Index(i).Interface(), typ); err != nil {
+			if err = writeXML(w, r.Index(i).Interface(), typ, dialect); err != nil {
 				return
 			}
 			if _, err = io.WriteString(w, "</value>\n"); err != nil {
@@ -448,7 +509,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 			return
 		}
 	case reflect.Interface:
-		return WriteXML(w, r.Elem(), typ)
+		return writeXML(w, r.Elem(), typ, dialect)
 	case reflect.Map:
 		if _, err = io.WriteString(w, "<struct>\n"); err != nil {
 			return
@@ -463,7 +524,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 			if _, err = io.WriteString(w, "</name><value>"); err != nil {
 				return
 			}
-			if err = WriteXML(w, r.MapIndex(key).Interface(), typ); err != nil {
+			if err = writeXML(w, r.MapIndex(key).Interface(), typ, dialect); err != nil {
 				return
 			}
 			if _, err = io.WriteString(w, "</value></member>\n"); err != nil {
@@ -473,7 +534,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 		_, err = io.WriteString(w, "</struct>")
 		return
 	case reflect.Ptr:
-		return WriteXML(w, reflect.Indirect(r), typ)
+		return writeXML(w, reflect.Indirect(r), typ, dialect)
 	case reflect.String:
 		if typ {
 			_, err = fmt.Fprintf(w, "<string>%v</string>", xmlEscape(v.(string)))
@@ -500,7 +561,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 			if _, err = io.WriteString(w, "</name><value>"); err != nil {
 				return
 			}
-			if err = WriteXML(w, r.Field(i).Interface(), true); err != nil {
+			if err = writeXML(w, r.Field(i).Interface(), true, dialect); err != nil {
 				return
 			}
 			if _, err = io.WriteString(w, "</value></member>"); err != nil {
@@ -510,7 +571,7 @@ func WriteXML(w io.Writer, v interface{}, typ bool) (err error) {
 		_, err = io.WriteString(w, "</struct>")
 		return
 	case reflect.UnsafePointer:
-		return WriteXML(w, r.Elem(), typ)
+		return writeXML(w, r.Elem(), typ, dialect)
 	}
 	return
 }
@@ -552,6 +613,11 @@ func getStructFieldName(sf reflect.StructField) string {
 // Marshal marshals the named thing (methodResponse if name == "", otherwise a methodCall)
 // into the w Writer
 func Marshal(w io.Writer, name string, args ...interface{}) (err error) {
+	return MarshalDialect(w, IntDialectInt, name, args...)
+}
+
+// MarshalDialect is like Marshal, tagging integers according to dialect
+func MarshalDialect(w io.Writer, dialect IntDialect, name string, args ...interface{}) (err error) {
 	if name == "" {
 		if _, err = io.WriteString(w, "<methodResponse>"); err != nil {
 			return
@@ -584,7 +650,7 @@ func Marshal(w io.Writer, name string, args ...interface{}) (err error) {
 		if _, err = io.WriteString(w, "  <param><value>"); err != nil {
 			return
 		}
-		if err = WriteXML(w, arg, true); err != nil {
+		if err = writeXML(w, arg, true, dialect); err != nil {
 			return
 		}
 		if _, err = io.WriteString(w, "</value></param>\n"); err != nil {
@@ -647,12 +713,15 @@ func Unmarshal(r io.Reader) (name string, params []interface{}, fault *Fault, e
 				e = fmt.Errorf("no faultCode in fault: %v", fmap)
 				return
 			}
-			fcode, ok := code.(int)
-			if !ok {
+			switch fcode := code.(type) {
+			case int:
+				fault.Code = fcode
+			case int64:
+				fault.Code = int(fcode)
+			default:
 				e = fmt.Errorf("faultCode not int? %v", code)
 				return
 			}
-			fault.Code = int(fcode)
 			msg, ok := fmap["faultString"]
 			if !ok {
 				e = fmt.Errorf("no faultString in fault: %v", fmap)
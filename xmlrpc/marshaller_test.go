@@ -0,0 +1,130 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalDialect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		dialect  IntDialect
+		value    interface{}
+		expected string
+	}{
+		{"int small", IntDialectInt, 42, "<int>42</int>"},
+		{"int large", IntDialectInt, int64(5000000000), "<int>5000000000</int>"},
+		{"i4 small", IntDialectI4, 42, "<i4>42</i4>"},
+		{"i4 unsigned", IntDialectI4, uint8(7), "<i4>7</i4>"},
+		{"i8 small", IntDialectI8, 42, "<int>42</int>"},
+		{"i8 max int32", IntDialectI8, math.MaxInt32, "<int>2147483647</int>"},
+		{"i8 large", IntDialectI8, int64(5000000000), "<i8>5000000000</i8>"},
+		{"i8 negative", IntDialectI8, int64(math.MinInt32) - 1, "<i8>-2147483649</i8>"},
+		{"i8 unsigned", IntDialectI8, uint64(math.MaxUint32), "<i8>4294967295</i8>"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, MarshalDialect(&buf, tc.dialect, "test", tc.value))
+			require.Contains(t, buf.String(), "<value>"+tc.expected+"</value>")
+		})
+	}
+
+	t.Run("i4 out of range", func(t *testing.T) {
+		for _, v := range []interface{}{int64(math.MaxInt32) + 1, int64(math.MinInt32) - 1, uint32(math.MaxUint32)} {
+			var buf bytes.Buffer
+			require.Error(t, MarshalDialect(&buf, IntDialectI4, "test", v), "%v", v)
+		}
+		var buf bytes.Buffer
+		require.Error(t, MarshalDialect(&buf, IntDialectI4, "test", []interface{}{1, int64(5000000000)}), "nested")
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, dialect := range []IntDialect{IntDialectInt, IntDialectI4, IntDialectI8} {
+			var buf bytes.Buffer
+			require.NoError(t, MarshalDialect(&buf, dialect, "test", 42, int64(math.MaxInt32)))
+			_, params, _, err := Unmarshal(&buf)
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{42, math.MaxInt32}, params)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, MarshalDialect(&buf, IntDialectI4, "test", []interface{}{1, map[string]interface{}{"a": 2}}))
+		require.Contains(t, buf.String(), "<i4>1</i4>")
+		require.Contains(t, buf.String(), "<i4>2</i4>")
+		require.NotContains(t, buf.String(), "<int>")
+	})
+
+	t.Run("default", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Marshal(&buf, "test", 42))
+		require.Contains(t, buf.String(), "<int>42</int>")
+	})
+}
+
+func TestUnmarshalIntegers(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected interface{}
+	}{
+		{"<i8>5000000000</i8>", int64(5000000000)},
+		{"<i8>-5000000000</i8>", int64(-5000000000)},
+		{"<i8>42</i8>", int64(42)},
+		{"<int>42</int>", 42},
+		{"<i4>-42</i4>", -42},
+		{"<int>5000000000</int>", int64(5000000000)},
+	} {
+		_, params, _, err := Unmarshal(strings.NewReader(
+			"<methodResponse><params><param><value>" + tc.value + "</value></param></params></methodResponse>"))
+		require.NoError(t, err, tc.value)
+		require.Equal(t, []interface{}{tc.expected}, params, tc.value)
+	}
+
+	t.Run("fault code", func(t *testing.T) {
+		_, _, fault, err := Unmarshal(strings.NewReader("<methodResponse><fault><value><struct>" +
+			"<member><name>faultCode</name><value><i8>-506</i8></value></member>" +
+			"<member><name>faultString</name><value><string>Method not defined</string></value></member>" +
+			"</struct></value></fault></methodResponse>"))
+		require.NoError(t, err)
+		require.Equal(t, -506, fault.Code)
+	})
+}
+
+func TestUnmarshalNil(t *testing.T) {
+	for _, value := range []string{"<nil/>", "<ex:nil/>", "<nil></nil>"} {
+		_, params, _, err := Unmarshal(strings.NewReader("<methodResponse><params><param><value><struct>" +
+			"<member><name>name</name><value><string>ABC</string></value></member>" +
+			"<member><name>extra</name><value>" + value + "</value></member>" +
+			"</struct></value></param><param><value><array><data>" +
+			"<value>" + value + "</value><value><int>1</int></value>" +
+			"</data></array></value></param></params></methodResponse>"))
+		require.NoError(t, err, value)
+		require.Equal(t, []interface{}{
+			map[string]interface{}{"name": "ABC", "extra": nil},
+			[]interface{}{nil, 1},
+		}, params, value)
+	}
+}
+
+func TestStringWhitespace(t *testing.T) {
+	for _, s := range []string{
+		"first line\nsecond line",
+		"\n  leading and trailing  \n",
+		"windows\r\nline endings\r\n",
+		"lone\rcarriage return",
+		"\ttabs\t",
+		"   ",
+		"",
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, Marshal(&buf, "test", s, []interface{}{s}, map[string]interface{}{"note": s}))
+		_, params, _, err := Unmarshal(&buf)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{s, []interface{}{s}, map[string]interface{}{"note": s}}, params, "%q", s)
+	}
+}
@@ -0,0 +1,202 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// NewSCGIClient returns a new instance of Client which calls rTorrent over SCGI (scgi_port or scgi_local in rtorrent.rc)
+// instead of HTTP. The address is either:
+//  - a unix socket: "/path/to/rtorrent.sock", "unix:///path/to/rtorrent.sock" or "scgi:///path/to/rtorrent.sock"
+//  - a TCP address: "scgi://host:port" or "host:port"
+// SCGI is implemented as the transport of the http.Client, so the other settings of the Client apply as usual, except
+// WithHTTPClient which would replace it. Headers set with WithHeader are sent as HTTP_* variables like a web server would.
+func NewSCGIClient(addr string) *Client {
+	network, address := parseSCGIAddr(addr)
+	return &Client{
+		// the requests need a valid URL, even though it isn't used to connect
+		addr:       "scgi://" + address,
+		httpClient: &http.Client{Transport: &scgiTransport{network: network, address: address}},
+		headers:    make(http.Header),
+	}
+}
+
+// IsSCGIAddr checks if the address is one NewSCGIClient should be used for, i.e. it has the scgi or unix scheme, or is
+// the absolute path of a unix socket
+func IsSCGIAddr(addr string) bool {
+	return strings.HasPrefix(addr, "scgi://") || strings.HasPrefix(addr, "unix://") || strings.HasPrefix(addr, "/")
+}
+
+// parseSCGIAddr returns the network and address to dial for a NewSCGIClient address
+func parseSCGIAddr(addr string) (network, address string) {
+	for _, scheme := range []string{"scgi://", "unix://"} {
+		addr = strings.TrimPrefix(addr, scheme)
+	}
+	if strings.HasPrefix(addr, "/") {
+		return "unix", addr
+	}
+	return "tcp", strings.TrimSuffix(addr, "/")
+}
+
+// scgiTransport is a http.RoundTripper speaking SCGI with the server at address
+type scgiTransport struct {
+	network string
+	address string
+}
+
+// RoundTrip sends the request over a new connection, which is closed along with the body of the response
+func (t *scgiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read request body")
+		}
+	}
+
+	ctx := req.Context()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, t.network, t.address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// close the connection when the request is cancelled, which aborts pending reads and writes
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	fail := func(err error) (*http.Response, error) {
+		close(done)
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	if _, err := conn.Write(scgiRequest(req, body)); err != nil {
+		return fail(errors.Wrap(err, "failed to send SCGI request"))
+	}
+	resp, err := readSCGIResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fail(err)
+	}
+	resp.Body = &scgiBody{ReadCloser: resp.Body, conn: conn, done: done}
+	return resp, nil
+}
+
+// scgiRequest encodes the request: a netstring of the headers, CONTENT_LENGTH first, followed by the body
+func scgiRequest(req *http.Request, body []byte) []byte {
+	var headers bytes.Buffer
+	add := func(name, value string) {
+		headers.WriteString(name)
+		headers.WriteByte(0)
+		headers.WriteString(value)
+		headers.WriteByte(0)
+	}
+	add("CONTENT_LENGTH", strconv.Itoa(len(body)))
+	add("SCGI", "1")
+	add("REQUEST_METHOD", req.Method)
+	// the address of the Client isn't a URL, rTorrent ignores the URI anyway
+	add("REQUEST_URI", "/")
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(req.Header[name], ", ")
+		if name == "Content-Type" {
+			add("CONTENT_TYPE", value)
+			continue
+		}
+		add("HTTP_"+strings.ToUpper(strings.Replace(name, "-", "_", -1)), value)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d:", headers.Len())
+	buf.Write(headers.Bytes())
+	buf.WriteByte(',')
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// readSCGIResponse reads a CGI style response ("Status: 200 OK" header), or a HTTP response which some servers send
+func readSCGIResponse(r *bufio.Reader, req *http.Request) (*http.Response, error) {
+	if prefix, err := r.Peek(5); err == nil && string(prefix) == "HTTP/" {
+		resp, err := http.ReadResponse(r, req)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SCGI response")
+		}
+		return resp, nil
+	}
+
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SCGI response")
+	}
+	resp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		Header:        http.Header(header),
+		Body:          ioutil.NopCloser(r),
+		ContentLength: -1,
+		Request:       req,
+	}
+	if status := header.Get("Status"); status != "" {
+		code, err := strconv.Atoi(strings.SplitN(status, " ", 2)[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid SCGI response status: %q", status)
+		}
+		resp.Status, resp.StatusCode = status, code
+		resp.Header.Del("Status")
+	}
+	if length := header.Get("Content-Length"); length != "" {
+		if n, err := strconv.ParseInt(length, 10, 64); err == nil && n >= 0 {
+			resp.ContentLength = n
+			resp.Body = ioutil.NopCloser(io.LimitReader(r, n))
+		}
+	}
+	return resp, nil
+}
+
+// scgiBody is the body of a SCGI response, closing it closes the connection
+type scgiBody struct {
+	io.ReadCloser
+	conn net.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+func (b *scgiBody) Close() error {
+	err := errors.New("body already closed")
+	b.once.Do(func() {
+		close(b.done)
+		b.ReadCloser.Close()
+		err = b.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,183 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// serveSCGI answers the SCGI requests received by the listener with the result of handler
+// The headers of the last request are sent to the returned channel.
+func serveSCGI(t *testing.T, ln net.Listener, handler func(name string, args []interface{}) interface{}) <-chan map[string]string {
+	requests := make(chan map[string]string, 10)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				length, err := r.ReadString(':')
+				if err != nil {
+					return
+				}
+				n, err := strconv.Atoi(strings.TrimSuffix(length, ":"))
+				if err != nil {
+					return
+				}
+				netstring := make([]byte, n+1)
+				if _, err := io.ReadFull(r, netstring); err != nil || netstring[n] != ',' {
+					return
+				}
+				fields := strings.Split(string(netstring[:n]), "\x00")
+				headers := map[string]string{}
+				for i := 0; i+1 < len(fields); i += 2 {
+					headers[fields[i]] = fields[i+1]
+				}
+				headers["order"] = fields[0]
+				requests <- headers
+
+				contentLength, _ := strconv.Atoi(headers["CONTENT_LENGTH"])
+				name, args, _, err := Unmarshal(io.LimitReader(r, int64(contentLength)))
+				if err != nil {
+					return
+				}
+				var body bytes.Buffer
+				if err := Marshal(&body, "", handler(name, args)); err != nil {
+					return
+				}
+				fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n", body.Len())
+				_, _ = conn.Write(body.Bytes())
+			}()
+		}
+	}()
+	return requests
+}
+
+func TestSCGIRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "/tmp/rtorrent.sock", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/xml")
+	req.Header.Add("X-Api-Key", "secret")
+	body := []byte("<methodCall/>")
+
+	headers := "CONTENT_LENGTH\x0013\x00SCGI\x001\x00REQUEST_METHOD\x00POST\x00REQUEST_URI\x00/\x00" +
+		"CONTENT_TYPE\x00text/xml\x00HTTP_X_API_KEY\x00secret\x00"
+	require.Equal(t, fmt.Sprintf("%d:%s,<methodCall/>", len(headers), headers), string(scgiRequest(req, body)))
+}
+
+func TestSCGIResponse(t *testing.T) {
+	t.Run("cgi status", func(t *testing.T) {
+		resp, err := readSCGIResponse(bufio.NewReader(strings.NewReader(
+			"Status: 404 Not Found\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nerrorextra")), nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+		require.Empty(t, resp.Header.Get("Status"))
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "error", string(body))
+	})
+
+	t.Run("no status", func(t *testing.T) {
+		resp, err := readSCGIResponse(bufio.NewReader(strings.NewReader("Content-Type: text/xml\r\n\r\n<xml/>")), nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "<xml/>", string(body))
+	})
+
+	t.Run("http", func(t *testing.T) {
+		resp, err := readSCGIResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 200 OK\r\nContent-Length: 6\r\n\r\n<xml/>")), nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := readSCGIResponse(bufio.NewReader(strings.NewReader("Status: OK\r\n\r\n")), nil)
+		require.Error(t, err)
+	})
+}
+
+func TestSCGIClient(t *testing.T) {
+	handler := func(name string, args []interface{}) interface{} {
+		return append([]interface{}{name}, args...)
+	}
+
+	t.Run("unix", func(t *testing.T) {
+		sock := filepath.Join(t.TempDir(), "rtorrent.sock")
+		ln, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		requests := serveSCGI(t, ln, handler)
+
+		for _, addr := range []string{sock, "unix://" + sock, "scgi://" + sock} {
+			require.True(t, IsSCGIAddr(addr), addr)
+			result, err := NewSCGIClient(addr).WithHeader("X-Api-Key", "secret").Call("d.name", "ABC")
+			require.NoError(t, err, addr)
+			require.Equal(t, []interface{}{[]interface{}{"d.name", "ABC"}}, result, addr)
+
+			headers := <-requests
+			require.Equal(t, "CONTENT_LENGTH", headers["order"])
+			require.Equal(t, "1", headers["SCGI"])
+			require.Equal(t, "POST", headers["REQUEST_METHOD"])
+			require.Equal(t, "text/xml", headers["CONTENT_TYPE"])
+			require.Equal(t, "secret", headers["HTTP_X_API_KEY"])
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		serveSCGI(t, ln, handler)
+
+		for _, addr := range []string{"scgi://" + ln.Addr().String(), "scgi://" + ln.Addr().String() + "/", ln.Addr().String()} {
+			result, err := NewSCGIClient(addr).Call("system.multicall", []interface{}{1, "two"})
+			require.NoError(t, err, addr)
+			require.Equal(t, []interface{}{[]interface{}{"system.multicall", []interface{}{1, "two"}}}, result, addr)
+		}
+		require.False(t, IsSCGIAddr(ln.Addr().String()))
+		require.False(t, IsSCGIAddr("http://"+ln.Addr().String()))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, err := NewSCGIClient(filepath.Join(t.TempDir(), "missing.sock")).Call("d.name", "ABC")
+		require.Error(t, err)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		go func() {
+			// accept the connection but never answer
+			conn, err := ln.Accept()
+			if err == nil {
+				defer conn.Close()
+				_, _ = io.Copy(ioutil.Discard, conn)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = NewSCGIClient("scgi://"+ln.Addr().String()).CallContext(ctx, "d.name", "ABC")
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.DeadlineExceeded), err.Error())
+	})
+}